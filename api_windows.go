@@ -21,10 +21,7 @@ var (
 	wslUnregisterDistribution       = wslAPIDll.NewProc("WslUnregisterDistribution")
 )
 
-const (
-	lxssRegistry = registry.CURRENT_USER
-	lxssPath     = `Software\Microsoft\Windows\CurrentVersion\Lxss\`
-)
+const lxssRegistry = registry.CURRENT_USER
 
 // Windows' typedefs.
 type wBOOL = int     // Windows' BOOL
@@ -0,0 +1,132 @@
+package wsl
+
+// This file contains the abstraction over the system that the rest of the
+// package is built on. By default it is implemented by wslapi.dll, but it can
+// be replaced in order to run code that depends on this package without WSL.
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotSupported is returned by the default backend on platforms where WSL is not available.
+var ErrNotSupported = errors.New("WSL is not supported on this platform")
+
+// Backend is the set of low-level operations used by this package to interact
+// with WSL. The default backend wraps around wslapi.dll (and wsl.exe), but it
+// can be replaced with SetBackend. See NewFakeBackend for an in-memory
+// implementation meant for testing.
+type Backend interface {
+	// RegisterDistribution is analogous to Win32's WslRegisterDistribution.
+	RegisterDistribution(distroName string, rootFsPath string) error
+
+	// UnregisterDistribution is analogous to Win32's WslUnregisterDistribution.
+	UnregisterDistribution(distroName string) error
+
+	// ConfigureDistribution is analogous to Win32's WslConfigureDistribution.
+	// Only the mutable fields of the configuration are taken into account.
+	ConfigureDistribution(distroName string, config Configuration) error
+
+	// GetDistributionConfiguration is analogous to Win32's WslGetDistributionConfiguration.
+	GetDistributionConfiguration(distroName string) (Configuration, error)
+
+	// Launch is analogous to Win32's WslLaunch. The backend must not hold on to the
+	// standard streams after returning: they are closed by the caller once the process
+	// has started.
+	Launch(distroName string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error)
+
+	// LaunchAsUser is like Launch, but the command runs as the specified Linux user
+	// (a user name) instead of the default user of the distro.
+	LaunchAsUser(distroName string, user string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error)
+
+	// LaunchExec is like LaunchAsUser, but the program is run directly with the arguments in
+	// argv, without any shell, as `wsl.exe --exec` does. An empty user stands for the default
+	// user. The program runs in dir ("~" being the home directory of the user), or in the
+	// current working directory if dir is empty.
+	LaunchExec(distroName string, user string, dir string, argv []string, stdin, stdout, stderr *os.File) (Process, error)
+
+	// LaunchInteractive is analogous to Win32's WslLaunchInteractive.
+	LaunchInteractive(distroName string, command string, useCWD bool) (exitCode uint32, err error)
+
+	// Registry gives access to the Lxss registry key, where WSL stores
+	// information about the registered distros.
+	Registry() RegistryReader
+
+	// Shutdown is analogous to `wsl.exe --shutdown`.
+	Shutdown(ctx context.Context) error
+
+	// Terminate is analogous to `wsl.exe --terminate <distroName>`.
+	Terminate(ctx context.Context, distroName string) error
+
+	// SetAsDefault is analogous to `wsl.exe --set-default <distroName>`.
+	SetAsDefault(ctx context.Context, distroName string) error
+
+	// Export is analogous to `wsl.exe --export <distroName> -`, writing
+	// the exported filesystem into dst.
+	Export(ctx context.Context, distroName string, dst io.Writer, format Format) error
+
+	// Import is analogous to `wsl.exe --import <distroName> <installDir> <source>`.
+	// A version of zero means that the default WSL version is used.
+	Import(ctx context.Context, distroName string, installDir string, source string, format Format, version uint8) error
+
+	// ImportInPlace is analogous to `wsl.exe --import-in-place <distroName> <vhdx>`.
+	ImportInPlace(ctx context.Context, distroName string, vhdx string) error
+
+	// States is analogous to `wsl.exe --list --verbose`. It returns the state of
+	// every registered distro, indexed by name.
+	States(ctx context.Context) (map[string]State, error)
+
+	// SetVersion is analogous to `wsl.exe --set-version <distroName> <version>`.
+	// The progress of the conversion is written into progress, unless it is nil.
+	SetVersion(ctx context.Context, distroName string, version uint8, progress io.Writer) error
+
+	// SetDefaultEnvironment writes the DefaultEnvironment value (REG_MULTI_SZ) of the
+	// Lxss registry key of the distro with the specified GUID.
+	SetDefaultEnvironment(guid string, env []string) error
+
+	// SetDistributionName writes the DistributionName value (REG_SZ) of the Lxss
+	// registry key of the distro with the specified GUID.
+	SetDistributionName(guid string, name string) error
+
+	// SetBasePath writes the BasePath value (REG_SZ) of the Lxss registry key of the
+	// distro with the specified GUID.
+	SetBasePath(guid string, basePath string) error
+}
+
+// Process is a process launched by a Backend.
+type Process interface {
+	// Wait blocks until the process exits and releases its resources.
+	// It returns the exit code of the process.
+	Wait() (exitCode uint32, err error)
+
+	// Kill terminates the process without waiting for it to exit.
+	// Wait then returns ActiveProcess as the exit code.
+	Kill() error
+
+	// Interrupt sends SIGTERM to the Linux processes started by the command, without
+	// waiting for them to exit.
+	Interrupt() error
+}
+
+// backend is the Backend used by all the functionality of this package.
+var backend = defaultBackend()
+
+// SetBackend replaces the Backend used by this package. It returns a function
+// that restores the previous one. Passing a nil Backend sets the default one.
+//
+// It is not safe to call SetBackend while other functions of this package
+// are in use.
+func SetBackend(b Backend) (restore func()) {
+	if b == nil {
+		b = defaultBackend()
+	}
+
+	prev := backend
+	backend = b
+
+	return func() {
+		backend = prev
+	}
+}
@@ -0,0 +1,841 @@
+package wsl
+
+// This file contains an in-memory Backend, meant to test code that builds on this package
+// without needing a Windows machine with WSL installed.
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FakeBackend is an in-memory Backend that emulates WSL. Like WSL, it stores the
+// registered distros, their configuration and the default distro in the Lxss registry
+// key, which is emulated by a FakeRegistry. Processes launched into its distros are
+// emulated with scripts (see FakeBackend.Script).
+//
+// Use it with SetBackend:
+//
+//	restore := wsl.SetBackend(wsl.NewFakeBackend())
+//	defer restore()
+type FakeBackend struct {
+	mu sync.Mutex
+
+	registry  *FakeRegistry
+	processes map[string]map[*fakeProcess]struct{} // Running processes, indexed by the GUID of their distro
+	scripts   map[string]FakeProcessFunc
+}
+
+// FakeProcess is the view that a FakeProcessFunc has of the process it emulates.
+type FakeProcess struct {
+	Distro  string // Name of the distro the process was launched into
+	User    string // User the process runs as. Empty for the default user of the distro.
+	Command string // Command the process was launched with. With Exec, its arguments quoted with ShellQuote.
+	UseCWD  bool   // Whether the process was launched in the current working directory
+	Exec    bool   // Whether the process was launched without a shell (see Backend.LaunchExec)
+	Dir     string // Directory the process was launched in with Exec, if not the current one. "~" stands for the home directory.
+
+	Stdin  io.Reader // Standard input of the process
+	Stdout io.Writer // Standard output of the process
+	Stderr io.Writer // Standard error of the process
+
+	Killed      <-chan struct{} // Closed when the process is killed (or its distro terminated)
+	Interrupted <-chan struct{} // Closed when the process is interrupted (see Cmd.Interrupt). It is up to the script to exit.
+}
+
+// FakeProcessFunc emulates a Linux process launched into a FakeBackend's distro.
+// It returns the exit code of the process.
+type FakeProcessFunc func(p *FakeProcess) (exitCode uint32)
+
+// FakeResult returns a FakeProcessFunc that writes the provided stdout and stderr
+// and exits with the provided exit code.
+func FakeResult(stdout, stderr string, exitCode uint32) FakeProcessFunc {
+	return func(p *FakeProcess) uint32 {
+		if _, err := io.WriteString(p.Stdout, stdout); err != nil {
+			return WindowsError
+		}
+		if _, err := io.WriteString(p.Stderr, stderr); err != nil {
+			return WindowsError
+		}
+		return exitCode
+	}
+}
+
+// NewFakeBackend creates a FakeBackend with no distros registered.
+func NewFakeBackend() *FakeBackend {
+	reg := NewFakeRegistry()
+	reg.createKey("AppxInstallerCache")
+	return NewFakeBackendWithRegistry(reg)
+}
+
+// NewFakeBackendWithRegistry creates a FakeBackend whose state is stored in the provided
+// registry. Any distro already present in the registry is considered registered.
+func NewFakeBackendWithRegistry(reg *FakeRegistry) *FakeBackend {
+	return &FakeBackend{
+		registry:  reg,
+		processes: make(map[string]map[*fakeProcess]struct{}),
+		scripts:   make(map[string]FakeProcessFunc),
+	}
+}
+
+// Registry returns the FakeRegistry where the FakeBackend stores its state.
+func (b *FakeBackend) Registry() RegistryReader {
+	return b.registry
+}
+
+// Script sets the behaviour of any process launched with the specified command,
+// in any of the distros. Commands without a script write an error message to
+// stderr and exit with code 127, like a shell would do with an unknown command.
+//
+// The command for (*Distro).Shell without WithCommand is the empty string.
+func (b *FakeBackend) Script(command string, f FakeProcessFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.scripts[command] = f
+}
+
+// RegisterDistribution emulates Win32's WslRegisterDistribution.
+func (b *FakeBackend) RegisterDistribution(distroName string, rootFsPath string) error {
+	if err := fakeCheckString(distroName); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(rootFsPath); err != nil {
+		return fmt.Errorf("failed syscall to wslRegisterDistribution")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.findDistro(distroName); err == nil {
+		return fmt.Errorf("failed syscall to wslRegisterDistribution")
+	}
+
+	return b.register(distroName, filepath.Dir(rootFsPath), 2)
+}
+
+// Import emulates `wsl.exe --import`. Tarballs are not inspected, but VHDs must have been
+// exported by a FakeBackend.
+func (b *FakeBackend) Import(ctx context.Context, distroName string, installDir string, source string, format Format, version uint8) error {
+	if err := fakeCheckString(distroName); err != nil {
+		return err
+	}
+
+	switch version {
+	case 0:
+		version = 2
+	case 1, 2:
+	default:
+		return fmt.Errorf("error importing distro %q: unknown WSL version %d", distroName, version)
+	}
+
+	switch format {
+	case FormatTar:
+	case FormatVHD:
+		if version != 2 {
+			return fakeWSLExeError("This operation is only supported by WSL2.", "Wsl/Service/RegisterDistro/WSL_E_WSL2_NEEDED")
+		}
+	default:
+		return fmt.Errorf("error importing distro %q: unknown format %d", distroName, format)
+	}
+
+	if err := fakeCheckSource(source, format); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.findDistro(distroName); err == nil {
+		return fakeWSLExeError("A distribution with the supplied name already exists.", "Wsl/Service/RegisterDistro/ERROR_ALREADY_EXISTS")
+	}
+
+	return b.register(distroName, installDir, version)
+}
+
+// ImportInPlace emulates `wsl.exe --import-in-place`. The VHD must have been exported by a FakeBackend.
+func (b *FakeBackend) ImportInPlace(ctx context.Context, distroName string, vhdx string) error {
+	if err := fakeCheckString(distroName); err != nil {
+		return err
+	}
+
+	if err := fakeCheckSource(vhdx, FormatVHD); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.findDistro(distroName); err == nil {
+		return fakeWSLExeError("A distribution with the supplied name already exists.", "Wsl/Service/RegisterDistro/ERROR_ALREADY_EXISTS")
+	}
+
+	return b.register(distroName, filepath.Dir(vhdx), 2)
+}
+
+// register creates the registry key of a new distro, with the same configuration that
+// a freshly registered Ubuntu would have.
+// The mutex must be held by the caller.
+func (b *FakeBackend) register(distroName string, basePath string, wslVersion uint8) error {
+	guid, err := newFakeGUID()
+	if err != nil {
+		return err
+	}
+
+	flags := flag_ENABLE_INTEROP | flag_APPEND_NT_PATH | flag_ENABLE_DRIVE_MOUNTING
+	if wslVersion == 2 {
+		flags |= flag_undocumented_WSL_VERSION
+	}
+
+	b.registry.createKey(guid)
+	for name, value := range map[string]any{
+		"DistributionName":  distroName,
+		"BasePath":          basePath,
+		"State":             uint64(1),
+		"Version":           uint64(2),
+		"Flags":             uint64(flags),
+		"DefaultUid":        uint64(0),
+		"KernelCommandLine": "BOOT_IMAGE=/kernel init=/init",
+		"DefaultEnvironment": []string{
+			"HOSTTYPE=x86_64",
+			"LANG=en_US.UTF-8",
+			"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/usr/games:/usr/local/games",
+			"TERM=xterm-256color",
+		},
+	} {
+		if err := b.registry.setValue(guid, name, value); err != nil {
+			return err
+		}
+	}
+
+	// The first distro to be registered becomes the default
+	if def, err := b.registry.StringValue("", "DefaultDistribution"); err != nil || def == "" {
+		return b.registry.setValue("", "DefaultDistribution", guid)
+	}
+
+	return nil
+}
+
+// UnregisterDistribution emulates Win32's WslUnregisterDistribution.
+func (b *FakeBackend) UnregisterDistribution(distroName string) error {
+	if err := fakeCheckString(distroName); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	guid, err := b.findDistro(distroName)
+	if err != nil {
+		return fmt.Errorf("failed syscall to WslUnregisterDistribution")
+	}
+
+	b.terminate(guid)
+	if err := b.registry.deleteKey(guid); err != nil {
+		return err
+	}
+
+	// Another distro becomes the default
+	if def, err := b.registry.StringValue("", "DefaultDistribution"); err != nil || def != guid {
+		return nil
+	}
+	for _, sk := range b.distroKeys() {
+		return b.registry.setValue("", "DefaultDistribution", sk)
+	}
+	return b.registry.deleteValue("", "DefaultDistribution")
+}
+
+// ConfigureDistribution emulates Win32's WslConfigureDistribution.
+func (b *FakeBackend) ConfigureDistribution(distroName string, config Configuration) error {
+	if err := fakeCheckString(distroName); err != nil {
+		return fmt.Errorf("failed to convert %q to UTF16", distroName)
+	}
+
+	flags, err := config.packFlags()
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	guid, err := b.findDistro(distroName)
+	if err != nil {
+		return fmt.Errorf("failed syscall to WslConfigureDistribution")
+	}
+
+	// The WSL version cannot be changed this way
+	oldFlags, err := b.registry.IntegerValue(guid, "Flags")
+	if err != nil {
+		return fmt.Errorf("failed syscall to WslConfigureDistribution")
+	}
+	flags = flags&^flag_undocumented_WSL_VERSION | wslFlags(oldFlags)&flag_undocumented_WSL_VERSION
+
+	if err := b.registry.setValue(guid, "Flags", uint64(flags)); err != nil {
+		return err
+	}
+	return b.registry.setValue(guid, "DefaultUid", uint64(config.DefaultUID))
+}
+
+// GetDistributionConfiguration emulates Win32's WslGetDistributionConfiguration.
+func (b *FakeBackend) GetDistributionConfiguration(distroName string) (Configuration, error) {
+	var conf Configuration
+
+	if err := fakeCheckString(distroName); err != nil {
+		return conf, fmt.Errorf("failed to convert %q to UTF16", distroName)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	guid, err := b.findDistro(distroName)
+	if err != nil {
+		return conf, fmt.Errorf("failed syscall to WslGetDistributionConfiguration")
+	}
+
+	version, err1 := b.registry.IntegerValue(guid, "Version")
+	uid, err2 := b.registry.IntegerValue(guid, "DefaultUid")
+	flags, err3 := b.registry.IntegerValue(guid, "Flags")
+	for _, err := range []error{err1, err2, err3} {
+		if err != nil {
+			return conf, fmt.Errorf("failed syscall to WslGetDistributionConfiguration")
+		}
+	}
+
+	conf.Version = uint8(version)
+	conf.DefaultUID = uint32(uid)
+	conf.unpackFlags(wslFlags(flags))
+
+	// A missing DefaultEnvironment is not an error: it means an empty environment
+	env, _ := b.registry.StringsValue(guid, "DefaultEnvironment")
+	conf.DefaultEnvironmentVariables = environmentFromMultiString(env)
+
+	return conf, nil
+}
+
+// Launch emulates Win32's WslLaunch by running the script associated to the command.
+func (b *FakeBackend) Launch(distroName string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error) {
+	return b.LaunchAsUser(distroName, "", command, useCWD, stdin, stdout, stderr)
+}
+
+// LaunchAsUser is like Launch, but the user is passed on to the script via FakeProcess.User.
+// An empty user stands for the default user of the distro.
+func (b *FakeBackend) LaunchAsUser(distroName string, user string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error) {
+	if err := fakeCheckString(distroName); err != nil {
+		return nil, err
+	}
+	if err := fakeCheckString(command); err != nil {
+		return nil, fmt.Errorf("failed to convert command %q to UTF16", command)
+	}
+
+	p, err := b.launchFiles(FakeProcess{Distro: distroName, User: user, Command: command, UseCWD: useCWD}, stdin, stdout, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed syscall to WslLaunch")
+	}
+
+	return p, nil
+}
+
+// LaunchExec is like LaunchAsUser, but the script is the one associated to the arguments quoted
+// with ShellQuote and joined by spaces. FakeProcess.Exec is set, and the directory is passed on
+// via FakeProcess.Dir.
+func (b *FakeBackend) LaunchExec(distroName string, user string, dir string, argv []string, stdin, stdout, stderr *os.File) (Process, error) {
+	if len(argv) == 0 {
+		return nil, errors.New("no program to run")
+	}
+	for _, s := range append([]string{distroName, user, dir}, argv...) {
+		if err := fakeCheckString(s); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := b.launchFiles(FakeProcess{Distro: distroName, User: user, Command: shellJoin(argv), UseCWD: dir == "", Exec: true, Dir: dir}, stdin, stdout, stderr)
+	if err != nil {
+		return nil, fakeWSLExeError("There is no distribution with the supplied name.", "Wsl/Service/WSL_E_DISTRO_NOT_FOUND")
+	}
+
+	return p, nil
+}
+
+// launchFiles is like launch, but it works on its own copies of the standard streams, as
+// the caller closes its copy of the files after launching.
+func (b *FakeBackend) launchFiles(view FakeProcess, stdin, stdout, stderr *os.File) (*fakeProcess, error) {
+	var files []*os.File
+	for _, f := range []*os.File{stdin, stdout, stderr} {
+		dup, err := dupFile(f)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, fmt.Errorf("failed to duplicate file %q: %v", f.Name(), err)
+		}
+		files = append(files, dup)
+	}
+
+	closers := make([]io.Closer, 0, len(files))
+	for _, f := range files {
+		closers = append(closers, f)
+	}
+
+	view.Stdin, view.Stdout, view.Stderr = files[0], files[1], files[2]
+	p, err := b.launch(view, closers)
+	if err != nil {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// LaunchInteractive emulates Win32's WslLaunchInteractive by running the script associated
+// to the command with the standard streams of the current process.
+func (b *FakeBackend) LaunchInteractive(distroName string, command string, useCWD bool) (uint32, error) {
+	if err := fakeCheckString(distroName); err != nil {
+		return WindowsError, err
+	}
+	if err := fakeCheckString(command); err != nil {
+		return WindowsError, fmt.Errorf("failed to convert command %q to UTF16", command)
+	}
+
+	p, err := b.launch(FakeProcess{Distro: distroName, Command: command, UseCWD: useCWD, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}, nil)
+	if err != nil {
+		return WindowsError, fmt.Errorf("failed syscall to WslLaunchInteractive")
+	}
+
+	return p.Wait()
+}
+
+// Shutdown kills all processes running in the FakeBackend's distros.
+func (b *FakeBackend) Shutdown(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error shutting WSL down: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for guid := range b.processes {
+		b.terminate(guid)
+	}
+	return nil
+}
+
+// Terminate kills all processes running in a distro.
+func (b *FakeBackend) Terminate(ctx context.Context, distroName string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error terminating distro %q: %w", distroName, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	guid, err := b.findDistro(distroName)
+	if err != nil {
+		return fmt.Errorf("error terminating distro %q: %v", distroName, err)
+	}
+
+	b.terminate(guid)
+	return nil
+}
+
+// SetAsDefault sets a particular distribution as the default one.
+func (b *FakeBackend) SetAsDefault(ctx context.Context, distroName string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error setting %q as default: %w", distroName, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	guid, err := b.findDistro(distroName)
+	if err != nil {
+		return fmt.Errorf("error setting %q as default: %v", distroName, err)
+	}
+
+	return b.registry.setValue("", "DefaultDistribution", guid)
+}
+
+// Export emulates `wsl.exe --export`. The exported tarball contains a single file,
+// etc/fake-distro, with the name of the distro. The exported VHD is a placeholder
+// starting with the VHDX signature.
+func (b *FakeBackend) Export(ctx context.Context, distroName string, dst io.Writer, format Format) error {
+	b.mu.Lock()
+	guid, err := b.findDistro(distroName)
+	var flags uint64
+	if err == nil {
+		flags, err = b.registry.IntegerValue(guid, "Flags")
+	}
+	b.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("error exporting distro %q: %v", distroName, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatTar:
+		return fakeExportTar(dst, distroName)
+	case FormatVHD:
+		if wslFlags(flags)&flag_undocumented_WSL_VERSION == 0 {
+			return fmt.Errorf("error exporting distro %q: VHD format is only supported for WSL2 distros", distroName)
+		}
+		_, err := fmt.Fprintf(dst, "vhdxfile%s", distroName)
+		return err
+	default:
+		return fmt.Errorf("error exporting distro %q: unknown format %d", distroName, format)
+	}
+}
+
+// States emulates `wsl.exe --list --verbose`. Distros are Running while any process
+// launched into them has not been waited for, and Stopped otherwise.
+func (b *FakeBackend) States(ctx context.Context) (map[string]State, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error listing distros: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	states := make(map[string]State)
+	for _, guid := range b.distroKeys() {
+		name, err := b.registry.StringValue(guid, "DistributionName")
+		if err != nil {
+			continue
+		}
+
+		states[name] = Stopped
+		if len(b.processes[guid]) > 0 {
+			states[name] = Running
+		}
+	}
+
+	return states, nil
+}
+
+// SetVersion emulates `wsl.exe --set-version`. The distro is terminated before it is
+// converted, and the conversion is instantaneous.
+func (b *FakeBackend) SetVersion(ctx context.Context, distroName string, version uint8, progress io.Writer) error {
+	if version != 1 && version != 2 {
+		return fmt.Errorf("error setting version of %q: unknown WSL version %d", distroName, version)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	guid, err := b.findDistro(distroName)
+	if err != nil {
+		return fakeWSLExeError("There is no distribution with the supplied name.", "Wsl/Service/WSL_E_DISTRO_NOT_FOUND")
+	}
+
+	flags, err := b.registry.IntegerValue(guid, "Flags")
+	if err != nil {
+		return err
+	}
+
+	b.terminate(guid)
+
+	if progress != nil {
+		if _, err := fmt.Fprintln(progress, "Conversion in progress, this may take a few minutes..."); err != nil {
+			return err
+		}
+	}
+
+	flags &^= uint64(flag_undocumented_WSL_VERSION)
+	if version == 2 {
+		flags |= uint64(flag_undocumented_WSL_VERSION)
+	}
+
+	if err := b.registry.setValue(guid, "Flags", flags); err != nil {
+		return err
+	}
+
+	if progress != nil {
+		if _, err := fmt.Fprintln(progress, "The operation completed successfully."); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetDefaultEnvironment writes the DefaultEnvironment value of the registry key of the distro.
+func (b *FakeBackend) SetDefaultEnvironment(guid string, env []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.registry.setValue(guid, "DefaultEnvironment", append([]string{}, env...))
+}
+
+// SetDistributionName writes the DistributionName value of the registry key of the distro.
+func (b *FakeBackend) SetDistributionName(guid string, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.registry.setValue(guid, "DistributionName", name)
+}
+
+// SetBasePath writes the BasePath value of the registry key of the distro.
+func (b *FakeBackend) SetBasePath(guid string, basePath string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.registry.setValue(guid, "BasePath", basePath)
+}
+
+// findDistro returns the GUID of the registry key of a distro.
+// Like in WSL, distro names are case-insensitive.
+// The mutex must be held by the caller.
+func (b *FakeBackend) findDistro(distroName string) (guid string, err error) {
+	for _, sk := range b.distroKeys() {
+		name, err := b.registry.StringValue(sk, "DistributionName")
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(name, distroName) {
+			return sk, nil
+		}
+	}
+	return "", fmt.Errorf("distro %q is not registered", distroName)
+}
+
+// distroKeys returns the names of the registry keys of the distros.
+// The mutex must be held by the caller.
+func (b *FakeBackend) distroKeys() []string {
+	subkeys, err := b.registry.SubKeyNames("")
+	if err != nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(subkeys))
+	for _, sk := range subkeys {
+		if sk == "AppxInstallerCache" {
+			continue
+		}
+		keys = append(keys, sk)
+	}
+	return keys
+}
+
+// terminate kills all the processes running in the distro with the specified GUID.
+// The mutex must be held by the caller.
+func (b *FakeBackend) terminate(guid string) {
+	for p := range b.processes[guid] {
+		p.kill()
+	}
+	delete(b.processes, guid)
+}
+
+// launch starts the script for the command in a new goroutine. The script is given the view
+// of the process, once its Killed and Interrupted channels are set. The closers are closed when
+// the process ends or is killed.
+func (b *FakeBackend) launch(view FakeProcess, closers []io.Closer) (*fakeProcess, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	guid, err := b.findDistro(view.Distro)
+	if err != nil {
+		return nil, err
+	}
+
+	script, ok := b.scripts[view.Command]
+	if !ok {
+		script = FakeResult("", fmt.Sprintf("%s: command not found\n", view.Command), 127)
+	}
+
+	if b.processes[guid] == nil {
+		b.processes[guid] = make(map[*fakeProcess]struct{})
+	}
+
+	p := &fakeProcess{
+		done:        make(chan struct{}),
+		killed:      make(chan struct{}),
+		interrupted: make(chan struct{}),
+		closers:     closers,
+		processes:   b.processes[guid],
+		mu:          &b.mu,
+	}
+	p.processes[p] = struct{}{}
+
+	view.Killed = p.killed
+	view.Interrupted = p.interrupted
+
+	go func() {
+		exitCode := script(&view)
+		p.release()
+		p.exit(exitCode)
+	}()
+
+	return p, nil
+}
+
+// fakeProcess is the Process returned by the FakeBackend.
+type fakeProcess struct {
+	done   chan struct{} // Closed when the process exits or is killed
+	killed chan struct{} // Closed when the process is killed
+
+	interrupted   chan struct{} // Closed when the process is interrupted
+	interruptOnce sync.Once
+
+	stateMu  sync.Mutex
+	exited   bool   // Whether the process has exited (or has been killed)
+	exitCode uint32 // Only valid once done is closed
+
+	closers     []io.Closer
+	releaseOnce sync.Once
+
+	processes map[*fakeProcess]struct{} // Running processes of the same distro
+	mu        *sync.Mutex               // The backend's mutex, guarding processes
+}
+
+// Wait waits for the script to return, or for the process to be killed.
+func (p *fakeProcess) Wait() (uint32, error) {
+	<-p.done
+
+	p.mu.Lock()
+	delete(p.processes, p)
+	p.mu.Unlock()
+
+	return p.exitCode, nil
+}
+
+// Kill kills the process. The script is notified via FakeProcess.Killed.
+func (p *fakeProcess) Kill() error {
+	p.kill()
+	return nil
+}
+
+// Interrupt notifies the script via FakeProcess.Interrupted, as SIGTERM would.
+func (p *fakeProcess) Interrupt() error {
+	p.interruptOnce.Do(func() { close(p.interrupted) })
+	return nil
+}
+
+// kill closes the process with exit code ActiveProcess, as TerminateProcess does
+// in the wslapi backend. It has no effect if the process has already exited.
+func (p *fakeProcess) kill() {
+	if !p.exit(ActiveProcess) {
+		return
+	}
+	close(p.killed)
+	p.release()
+}
+
+// exit marks the process as exited with the specified exit code. It returns false
+// if the process had already exited.
+func (p *fakeProcess) exit(exitCode uint32) bool {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	if p.exited {
+		return false
+	}
+	p.exited = true
+	p.exitCode = exitCode
+	close(p.done)
+	return true
+}
+
+// release closes the files held by the process.
+func (p *fakeProcess) release() {
+	p.releaseOnce.Do(func() {
+		for _, c := range p.closers {
+			c.Close()
+		}
+	})
+}
+
+// fakeExportTar writes a tarball with the contents of a distro exported by the FakeBackend.
+func fakeExportTar(dst io.Writer, distroName string) error {
+	tw := tar.NewWriter(dst)
+
+	contents := []byte(distroName + "\n")
+	if err := tw.WriteHeader(&tar.Header{
+		Name: "etc/fake-distro",
+		Mode: 0644,
+		Size: int64(len(contents)),
+	}); err != nil {
+		return err
+	}
+
+	if _, err := tw.Write(contents); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// fakeCheckSource emulates the checks wsl.exe performs on the file a distro is imported from.
+func fakeCheckSource(path string, format Format) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fakeWSLExeError("The system cannot find the file specified.", "Wsl/Service/RegisterDistro/ERROR_FILE_NOT_FOUND")
+	}
+	defer f.Close()
+
+	if format != FormatVHD {
+		return nil
+	}
+
+	signature := make([]byte, len("vhdxfile"))
+	if _, err := io.ReadFull(f, signature); err != nil || string(signature) != "vhdxfile" {
+		return fakeWSLExeError("The specified file is not a valid virtual disk.", "Wsl/Service/RegisterDistro/ERROR_INVALID_PARAMETER")
+	}
+
+	return nil
+}
+
+// fakeWSLExeError creates the error that wsl.exe would return.
+func fakeWSLExeError(message string, code string) error {
+	return &WSLExeError{
+		Code:    code,
+		Message: message,
+		Err:     errors.New("exit status 4294967295"),
+	}
+}
+
+// newFakeGUID generates a random GUID, formatted like the name of a distro's registry key.
+func newFakeGUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("could not generate GUID: %v", err)
+	}
+
+	// Version 4 (random) UUID
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("{%x-%x-%x-%x-%x}", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// fakeCheckString emulates the failure to convert strings with null characters to UTF16.
+func fakeCheckString(s string) error {
+	if strings.ContainsRune(s, 0) {
+		return errors.New("failed to convert distro name to UTF16")
+	}
+	return nil
+}
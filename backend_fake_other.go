@@ -0,0 +1,17 @@
+//go:build !windows && !plan9
+
+package wsl
+
+import (
+	"os"
+	"syscall"
+)
+
+// dupFile duplicates a file's descriptor, so that it remains usable after the original is closed.
+func dupFile(f *os.File) (*os.File, error) {
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
@@ -0,0 +1,15 @@
+package wsl
+
+import (
+	"os"
+	"syscall"
+)
+
+// dupFile duplicates a file's descriptor, so that it remains usable after the original is closed.
+func dupFile(f *os.File) (*os.File, error) {
+	fd, err := syscall.Dup(int(f.Fd()), -1)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
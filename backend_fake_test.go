@@ -0,0 +1,236 @@
+package wsl_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+	"wsl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendRegistration(t *testing.T) {
+	useFakeBackend(t)
+	rootfs := fakeRootFs(t)
+
+	d1 := wsl.Distro{Name: "FakeDistro1"}
+	d2 := wsl.Distro{Name: "FakeDistro2"}
+
+	err := d1.Register(rootfs)
+	require.NoError(t, err, "Unexpected error registering a distro")
+	err = d2.Register(rootfs)
+	require.NoError(t, err, "Unexpected error registering a second distro")
+
+	err = d1.Register(rootfs)
+	require.ErrorIs(t, err, wsl.ErrAlreadyRegistered, "Unexpected success registering a distro twice")
+
+	err = (&wsl.Distro{Name: "fakedistro1"}).Register(rootfs)
+	require.ErrorIs(t, err, wsl.ErrAlreadyRegistered, "Unexpected success registering a distro whose name only differs in case")
+
+	var nameErr *wsl.InvalidNameError
+	err = (&wsl.Distro{Name: "Null\x00Char"}).Register(rootfs)
+	require.ErrorAs(t, err, &nameErr, "Unexpected success registering a distro with a null character in its name")
+
+	err = (&wsl.Distro{Name: "Fake Distro"}).Import(context.Background(), rootfs, t.TempDir())
+	require.ErrorAs(t, err, &nameErr, "Unexpected success importing a distro with a space in its name")
+
+	list, err := wsl.RegisteredDistros()
+	require.NoError(t, err, "Unexpected error listing registered distros")
+	require.ElementsMatch(t, []wsl.Distro{d1, d2}, list, "Unexpected list of registered distros")
+
+	def, err := wsl.DefaultDistro()
+	require.NoError(t, err, "Unexpected error getting the default distro")
+	require.Equal(t, d1, def, "The first registered distro should be the default")
+
+	err = d2.SetAsDefault()
+	require.NoError(t, err, "Unexpected error setting a distro as default")
+	def, err = wsl.DefaultDistro()
+	require.NoError(t, err, "Unexpected error getting the default distro")
+	require.Equal(t, d2, def, "Unexpected default distro after calling SetAsDefault")
+
+	err = d2.Unregister()
+	require.NoError(t, err, "Unexpected error unregistering a distro")
+	err = d2.Unregister()
+	require.Error(t, err, "Unexpected success unregistering a distro twice")
+
+	reg, err := d2.IsRegistered()
+	require.NoError(t, err, "Unexpected error checking if a distro is registered")
+	require.False(t, reg, "Distro should not be registered after unregistering it")
+
+	def, err = wsl.DefaultDistro()
+	require.NoError(t, err, "Unexpected error getting the default distro")
+	require.Equal(t, d1, def, "The remaining distro should have become the default")
+}
+
+func TestFakeBackendWithRegistry(t *testing.T) {
+	reg, err := wsl.LoadFakeRegistry(filepath.Join("testdata", "registry", "two_distros.yaml"))
+	require.NoError(t, err, "Setup: could not load registry fixture")
+	t.Cleanup(wsl.SetBackend(wsl.NewFakeBackendWithRegistry(reg)))
+
+	list, err := wsl.RegisteredDistros()
+	require.NoError(t, err, "Unexpected error listing registered distros")
+	require.ElementsMatch(t, []wsl.Distro{{Name: "Ubuntu"}, {Name: "Debian"}}, list, "Unexpected list of registered distros")
+
+	def, err := wsl.DefaultDistro()
+	require.NoError(t, err, "Unexpected error getting the default distro")
+	require.Equal(t, wsl.Distro{Name: "Ubuntu"}, def, "Unexpected default distro")
+
+	c, err := (&wsl.Distro{Name: "debian"}).GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration of a distro from the fixture")
+	require.Equal(t, uint8(1), c.Version)
+	require.True(t, c.InteropEnabled)
+}
+
+func TestFakeBackendConfiguration(t *testing.T) {
+	useFakeBackend(t)
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	_, err := d.GetConfiguration()
+	require.Error(t, err, "Unexpected success getting the configuration of an unregistered distro")
+	require.Error(t, d.DefaultUID(1000), "Unexpected success configuring an unregistered distro")
+
+	require.NoError(t, d.Register(fakeRootFs(t)), "Setup: could not register fake distro")
+
+	c, err := d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration")
+	assert.Equal(t, uint8(2), c.Version)
+	assert.Equal(t, uint32(0), c.DefaultUID)
+	assert.True(t, c.InteropEnabled)
+	assert.True(t, c.PathAppended)
+	assert.True(t, c.DriveMountingEnabled)
+	assert.Contains(t, c.DefaultEnvironmentVariables, "PATH")
+
+	require.NoError(t, d.DefaultUID(1000), "Unexpected error setting DefaultUID")
+	require.NoError(t, d.InteropEnabled(false), "Unexpected error setting InteropEnabled")
+	require.NoError(t, d.PathAppended(false), "Unexpected error setting PathAppended")
+	require.NoError(t, d.DriveMountingEnabled(false), "Unexpected error setting DriveMountingEnabled")
+
+	c, err = d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration")
+	assert.Equal(t, uint32(1000), c.DefaultUID)
+	assert.False(t, c.InteropEnabled)
+	assert.False(t, c.PathAppended)
+	assert.False(t, c.DriveMountingEnabled)
+}
+
+func TestFakeBackendCommand(t *testing.T) {
+	fake := useFakeBackend(t)
+
+	d := registerFakeDistro(t, "FakeDistro")
+
+	fake.Script("exit 0", wsl.FakeResult("", "", 0))
+	fake.Script("exit 42", wsl.FakeResult("Hello\n", "Error!\n", 42))
+	fake.Script("echo Hello", wsl.FakeResult("Hello\n", "", 0))
+	scriptSleepInfinity(fake)
+	fake.Script("cat", func(p *wsl.FakeProcess) uint32 {
+		if _, err := io.Copy(p.Stdout, p.Stdin); err != nil {
+			return 1
+		}
+		return 0
+	})
+
+	t.Run("run", func(t *testing.T) {
+		err := d.Command(context.Background(), "exit 0").Run()
+		require.NoError(t, err, "Unexpected error running a command")
+	})
+
+	t.Run("unregistered distro", func(t *testing.T) {
+		d := wsl.Distro{Name: "NotRegistered"}
+		err := d.Command(context.Background(), "exit 0").Run()
+		require.Error(t, err, "Unexpected success running a command in an unregistered distro")
+		require.NotErrorIs(t, err, wsl.ExitError{}, "Unexpected ExitError running a command in an unregistered distro")
+	})
+
+	t.Run("unscripted command", func(t *testing.T) {
+		err := d.Command(context.Background(), "unknown command").Run()
+		require.ErrorIs(t, err, wsl.ExitError{}, "Expected an ExitError for an unscripted command")
+		require.Equal(t, uint32(127), err.(*wsl.ExitError).Code) //nolint: forcetypeassert, errorlint
+	})
+
+	t.Run("output", func(t *testing.T) {
+		out, err := d.Command(context.Background(), "echo Hello").Output()
+		require.NoError(t, err, "Unexpected error calling Output")
+		require.Equal(t, "Hello\n", string(out))
+	})
+
+	t.Run("output with exit error", func(t *testing.T) {
+		out, err := d.Command(context.Background(), "exit 42").Output()
+		require.ErrorIs(t, err, wsl.ExitError{}, "Expected an ExitError calling Output")
+		require.Equal(t, uint32(42), err.(*wsl.ExitError).Code)           //nolint: forcetypeassert, errorlint
+		require.Equal(t, "Error!\n", string(err.(*wsl.ExitError).Stderr)) //nolint: forcetypeassert, errorlint
+		require.Equal(t, "Hello\n", string(out))
+	})
+
+	t.Run("combined output", func(t *testing.T) {
+		out, err := d.Command(context.Background(), "exit 42").CombinedOutput()
+		require.ErrorIs(t, err, wsl.ExitError{}, "Expected an ExitError calling CombinedOutput")
+		require.Equal(t, "Hello\nError!\n", string(out))
+	})
+
+	t.Run("pipes", func(t *testing.T) {
+		cmd := d.Command(context.Background(), "cat")
+		stdin, err := cmd.StdinPipe()
+		require.NoError(t, err, "Unexpected error calling StdinPipe")
+		stdout, err := cmd.StdoutPipe()
+		require.NoError(t, err, "Unexpected error calling StdoutPipe")
+
+		require.NoError(t, cmd.Start(), "Unexpected error calling Start")
+
+		_, err = stdin.Write([]byte("Hello, wsl!\n"))
+		require.NoError(t, err, "Unexpected error writing to stdin")
+
+		line, err := bufio.NewReader(stdout).ReadString('\n')
+		require.NoError(t, err, "Unexpected error reading from stdout")
+		require.Equal(t, "Hello, wsl!\n", line)
+
+		require.NoError(t, stdin.Close(), "Unexpected error closing stdin")
+		require.NoError(t, cmd.Wait(), "Unexpected error calling Wait")
+	})
+
+	t.Run("cancel during run", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := d.Command(ctx, "sleep infinity").Run()
+		require.ErrorIs(t, err, context.DeadlineExceeded, "Expected the command to be cancelled")
+	})
+
+	t.Run("terminate during run", func(t *testing.T) {
+		cmd := d.Command(context.Background(), "sleep infinity")
+		require.NoError(t, cmd.Start(), "Unexpected error calling Start")
+		require.NoError(t, d.Terminate(), "Unexpected error terminating the distro")
+
+		err := cmd.Wait()
+		require.ErrorIs(t, err, wsl.ExitError{}, "Expected an ExitError after terminating the distro")
+		require.Equal(t, wsl.ActiveProcess, err.(*wsl.ExitError).Code) //nolint: forcetypeassert, errorlint
+	})
+}
+
+func TestFakeBackendShell(t *testing.T) {
+	fake := useFakeBackend(t)
+
+	d := registerFakeDistro(t, "FakeDistro")
+
+	fake.Script("", wsl.FakeResult("", "", 0))
+	fake.Script("exit 42", wsl.FakeResult("", "", 42))
+	fake.Script("pwd", func(p *wsl.FakeProcess) uint32 {
+		if !p.UseCWD {
+			return 1
+		}
+		return 0
+	})
+
+	require.NoError(t, d.Shell(), "Unexpected error starting an interactive shell")
+	require.NoError(t, d.Shell(wsl.WithCommand("pwd"), wsl.UseCWD()), "Unexpected error with UseCWD")
+
+	err := d.Shell(wsl.WithCommand("exit 42"))
+	require.ErrorIs(t, err, wsl.ExitError{}, "Expected an ExitError from Shell")
+	require.Equal(t, uint32(42), err.(*wsl.ExitError).Code) //nolint: forcetypeassert, errorlint
+
+	err = (&wsl.Distro{Name: "NotRegistered"}).Shell()
+	require.Error(t, err, "Unexpected success starting a shell in an unregistered distro")
+}
@@ -0,0 +1,21 @@
+package wsl
+
+import (
+	"os"
+	"syscall"
+)
+
+// dupFile duplicates a file's handle, so that it remains usable after the original is closed.
+func dupFile(f *os.File) (*os.File, error) {
+	proc, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return nil, err
+	}
+
+	var dup syscall.Handle
+	err = syscall.DuplicateHandle(proc, syscall.Handle(f.Fd()), proc, &dup, 0, false, syscall.DUPLICATE_SAME_ACCESS)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(dup), f.Name()), nil
+}
@@ -0,0 +1,114 @@
+//go:build !windows
+
+package wsl
+
+// This file contains the default Backend for platforms other than Windows, where WSL is not available.
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// unsupportedBackend is a Backend whose every operation fails with ErrNotSupported.
+type unsupportedBackend struct{}
+
+func defaultBackend() Backend {
+	return unsupportedBackend{}
+}
+
+func (unsupportedBackend) RegisterDistribution(distroName string, rootFsPath string) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) UnregisterDistribution(distroName string) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) ConfigureDistribution(distroName string, config Configuration) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) GetDistributionConfiguration(distroName string) (Configuration, error) {
+	return Configuration{}, ErrNotSupported
+}
+
+func (unsupportedBackend) Launch(distroName string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedBackend) LaunchAsUser(distroName string, user string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedBackend) LaunchExec(distroName string, user string, dir string, argv []string, stdin, stdout, stderr *os.File) (Process, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedBackend) LaunchInteractive(distroName string, command string, useCWD bool) (uint32, error) {
+	return WindowsError, ErrNotSupported
+}
+
+func (b unsupportedBackend) Registry() RegistryReader {
+	return b
+}
+
+func (unsupportedBackend) SubKeyNames(path string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedBackend) StringValue(path string, name string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (unsupportedBackend) IntegerValue(path string, name string) (uint64, error) {
+	return 0, ErrNotSupported
+}
+
+func (unsupportedBackend) StringsValue(path string, name string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedBackend) Shutdown(ctx context.Context) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) Terminate(ctx context.Context, distroName string) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) SetAsDefault(ctx context.Context, distroName string) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) Export(ctx context.Context, distroName string, dst io.Writer, format Format) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) Import(ctx context.Context, distroName string, installDir string, source string, format Format, version uint8) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) ImportInPlace(ctx context.Context, distroName string, vhdx string) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) States(ctx context.Context) (map[string]State, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedBackend) SetVersion(ctx context.Context, distroName string, version uint8, progress io.Writer) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) SetDefaultEnvironment(guid string, env []string) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) SetDistributionName(guid string, name string) error {
+	return ErrNotSupported
+}
+
+func (unsupportedBackend) SetBasePath(guid string, basePath string) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package wsl_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotSupported(t *testing.T) {
+	d := wsl.Distro{Name: "SomeDistro"}
+	rootfs := fakeRootFs(t)
+	someGUID := "{ee8aef7a-846f-4561-a028-79504ce65cd3}"
+	wslconfig := filepath.Join(t.TempDir(), ".wslconfig")
+	setProcessors := func(c *wsl.GlobalConfig) { c.Processors = 2 }
+	manifest := wsl.Manifest{Distros: []wsl.DistroManifest{{Name: d.Name, RootFs: rootfs}}}
+
+	testCases := map[string]func() error{
+		"Register":                   func() error { return d.Register(rootfs) },
+		"Unregister":                 func() error { return d.Unregister() },
+		"RegisteredDistros":          func() error { _, err := wsl.RegisteredDistros(); return err },
+		"IsRegistered":               func() error { _, err := d.IsRegistered(); return err },
+		"DefaultDistro":              func() error { _, err := wsl.DefaultDistro(); return err },
+		"GetConfiguration":           func() error { _, err := d.GetConfiguration(); return err },
+		"Properties":                 func() error { _, err := d.Properties(); return err },
+		"RegisteredDistroProperties": func() error { _, err := wsl.RegisteredDistroProperties(); return err },
+		"GUID":                       func() error { _, err := d.GUID(); return err },
+		"Pin":                        func() error { return d.Pin() },
+		"DistroByGUID":               func() error { _, err := wsl.DistroByGUID(someGUID); return err },
+		"DefaultUID":                 func() error { return d.DefaultUID(1000) },
+		"Configure":                  func() error { _, err := d.Configure(wsl.Configuration{}); return err },
+		"Update":                     func() error { _, err := d.Update(func(*wsl.Configuration) {}); return err },
+		"Move":                       func() error { return d.Move(context.Background(), filepath.Join(t.TempDir(), "new")) },
+		"Rename":                     func() error { return d.Rename("OtherDistro") },
+		"SetDefaultEnvironment":      func() error { return d.SetDefaultEnvironment(map[string]string{"FOO": "bar"}) },
+		"Command":                    func() error { return d.Command(context.Background(), "exit 0").Run() },
+		"CommandArgs":                func() error { return d.CommandArgs(context.Background(), "exit", "0").Run() },
+		"CommandArgs with Exec":      func() error { cmd := d.CommandArgs(context.Background(), "true"); cmd.Exec = true; return cmd.Run() },
+		"Command as a user":          func() error { cmd := d.Command(context.Background(), "exit 0"); cmd.User = "ubuntu"; return cmd.Run() },
+		"Shell":                      func() error { return d.Shell() },
+		"ShellContext":               func() error { return d.ShellContext(context.Background()) },
+		"RegisterContext":            func() error { return d.RegisterContext(context.Background(), rootfs) },
+		"UnregisterContext":          func() error { return d.UnregisterContext(context.Background()) },
+		"TerminateContext":           func() error { return d.TerminateContext(context.Background()) },
+		"ShutdownContext":            func() error { return wsl.ShutdownContext(context.Background()) },
+		"SetAsDefaultContext":        func() error { return d.SetAsDefaultContext(context.Background()) },
+		"WSLConf":                    func() error { _, err := d.WSLConf(context.Background()); return err },
+		"SetWSLConf":                 func() error { return d.SetWSLConf(context.Background(), wsl.WSLConf{}) },
+		"SetAsDefault":               func() error { return d.SetAsDefault() },
+		"Terminate":                  func() error { return d.Terminate() },
+		"Shutdown":                   func() error { return wsl.Shutdown() },
+		"Export":                     func() error { return d.ExportTo(context.Background(), io.Discard) },
+		"Import":                     func() error { return d.Import(context.Background(), rootfs, t.TempDir()) },
+		"ImportInPlace":              func() error { return d.ImportInPlace(context.Background(), rootfs) },
+		"Clone":                      func() error { _, err := d.Clone(context.Background(), "SomeClone"); return err },
+		"State":                      func() error { _, err := d.State(); return err },
+		"States":                     func() error { _, err := wsl.States(); return err },
+		"StateContext":               func() error { _, err := d.StateContext(context.Background()); return err },
+		"StatesContext":              func() error { _, err := wsl.StatesContext(context.Background()); return err },
+		"SetVersion":                 func() error { return d.SetVersion(context.Background(), 1) },
+		"Info":                       func() error { _, err := d.Info(); return err },
+		"RegisteredDistroInfo":       func() error { _, err := wsl.RegisteredDistroInfo(); return err },
+		"Apply":                      func() error { _, err := wsl.Apply(context.Background(), manifest, wsl.WithDryRun()); return err },
+		"UpdateGlobalConfig":         func() error { _, err := wsl.UpdateGlobalConfig(wslconfig, setProcessors); return err },
+	}
+
+	for name, f := range testCases {
+		f := f
+		t.Run(name, func(t *testing.T) {
+			err := f()
+			require.ErrorIs(t, err, wsl.ErrNotSupported, "Expected ErrNotSupported on a platform without WSL")
+		})
+	}
+}
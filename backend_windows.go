@@ -0,0 +1,401 @@
+package wsl
+
+// This file contains the default Backend, which wraps around wslapi.dll and wsl.exe.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// windowsBackend is the Backend that interacts with the real WSL.
+type windowsBackend struct{}
+
+func defaultBackend() Backend {
+	return windowsBackend{}
+}
+
+// RegisterDistribution is a wrapper around Win32's WslRegisterDistribution.
+func (windowsBackend) RegisterDistribution(distroName string, rootFsPath string) error {
+	distroUTF16, err := syscall.UTF16PtrFromString(distroName)
+	if err != nil {
+		return errors.New("failed to convert distro name to UTF16")
+	}
+
+	rootFsPathUTF16, err := syscall.UTF16PtrFromString(rootFsPath)
+	if err != nil {
+		return fmt.Errorf("failed to convert rootfs '%q' to UTF16", rootFsPath)
+	}
+
+	r1, _, _ := wslRegisterDistribution.Call(
+		uintptr(unsafe.Pointer(distroUTF16)),
+		uintptr(unsafe.Pointer(rootFsPathUTF16)))
+
+	if r1 != 0 {
+		return fmt.Errorf("failed syscall to wslRegisterDistribution")
+	}
+
+	return nil
+}
+
+// UnregisterDistribution is a wrapper around Win32's WslUnregisterDistribution.
+func (windowsBackend) UnregisterDistribution(distroName string) error {
+	distroUTF16, err := syscall.UTF16PtrFromString(distroName)
+	if err != nil {
+		return errors.New("failed to convert distro name to UTF16")
+	}
+
+	r1, _, _ := wslUnregisterDistribution.Call(uintptr(unsafe.Pointer(distroUTF16)))
+
+	if r1 != 0 {
+		return fmt.Errorf("failed syscall to WslUnregisterDistribution")
+	}
+	return nil
+}
+
+// ConfigureDistribution is a wrapper around Win32's WslConfigureDistribution.
+func (windowsBackend) ConfigureDistribution(distroName string, config Configuration) error {
+	distroUTF16, err := syscall.UTF16PtrFromString(distroName)
+	if err != nil {
+		return fmt.Errorf("failed to convert %q to UTF16", distroName)
+	}
+
+	flags, err := config.packFlags()
+	if err != nil {
+		return err
+	}
+
+	r1, _, _ := wslConfigureDistribution.Call(
+		uintptr(unsafe.Pointer(distroUTF16)),
+		uintptr(config.DefaultUID),
+		uintptr(flags),
+	)
+
+	if r1 != 0 {
+		return fmt.Errorf("failed syscall to WslConfigureDistribution")
+	}
+
+	return nil
+}
+
+// GetDistributionConfiguration is a wrapper around Win32's WslGetDistributionConfiguration.
+func (windowsBackend) GetDistributionConfiguration(distroName string) (Configuration, error) {
+	var conf Configuration
+
+	distroUTF16, err := syscall.UTF16PtrFromString(distroName)
+	if err != nil {
+		return conf, fmt.Errorf("failed to convert %q to UTF16", distroName)
+	}
+
+	var (
+		flags        wslFlags
+		envVarsBegin **char
+		envVarsLen   uint64 // size_t
+	)
+
+	r1, _, _ := wslGetDistributionConfiguration.Call(
+		uintptr(unsafe.Pointer(distroUTF16)),
+		uintptr(unsafe.Pointer(&conf.Version)),
+		uintptr(unsafe.Pointer(&conf.DefaultUID)),
+		uintptr(unsafe.Pointer(&flags)),
+		uintptr(unsafe.Pointer(&envVarsBegin)),
+		uintptr(unsafe.Pointer(&envVarsLen)),
+	)
+
+	if r1 != 0 {
+		return conf, fmt.Errorf("failed syscall to WslGetDistributionConfiguration")
+	}
+
+	conf.unpackFlags(flags)
+	conf.DefaultEnvironmentVariables = processEnvVariables(envVarsBegin, envVarsLen)
+	return conf, nil
+}
+
+// Launch is a wrapper around Win32's WslLaunch.
+func (windowsBackend) Launch(distroName string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error) {
+	distroUTF16, err := syscall.UTF16PtrFromString(distroName)
+	if err != nil {
+		return nil, errors.New("failed to convert distro name to UTF16")
+	}
+
+	marked, marker, err := markCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	commandUTF16, err := syscall.UTF16PtrFromString(marked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert command %q to UTF16", command)
+	}
+
+	var useCwd wBOOL
+	if useCWD {
+		useCwd = 1
+	}
+
+	var handle syscall.Handle
+
+	r1, _, _ := wslLaunch.Call(
+		uintptr(unsafe.Pointer(distroUTF16)),
+		uintptr(unsafe.Pointer(commandUTF16)),
+		uintptr(useCwd),
+		stdin.Fd(),
+		stdout.Fd(),
+		stderr.Fd(),
+		uintptr(unsafe.Pointer(&handle)))
+
+	if r1 != 0 {
+		return nil, fmt.Errorf("failed syscall to WslLaunch")
+	}
+	if handle == syscall.Handle(0) {
+		return nil, fmt.Errorf("syscall to WslLaunch returned a null handle")
+	}
+
+	return &windowsProcess{handle: handle, distroName: distroName, marker: marker}, nil
+}
+
+// LaunchAsUser runs the command via wsl.exe, as WslLaunch cannot choose the user. As with
+// Launch, the command is run by the default shell of the user.
+func (windowsBackend) LaunchAsUser(distroName string, user string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error) {
+	return launchDistro(distroName, user, command, useCWD, stdin, stdout, stderr)
+}
+
+// LaunchExec runs the program via wsl.exe --exec, as WslLaunch always goes through the shell.
+func (windowsBackend) LaunchExec(distroName string, user string, dir string, argv []string, stdin, stdout, stderr *os.File) (Process, error) {
+	return launchExec(distroName, user, dir, argv, stdin, stdout, stderr)
+}
+
+// LaunchInteractive is a wrapper around Win32's WslLaunchInteractive.
+func (windowsBackend) LaunchInteractive(distroName string, command string, useCWD bool) (uint32, error) {
+	distroUTF16, err := syscall.UTF16PtrFromString(distroName)
+	if err != nil {
+		return WindowsError, errors.New("failed to convert distro name to UTF16")
+	}
+
+	commandUTF16, err := syscall.UTF16PtrFromString(command)
+	if err != nil {
+		return WindowsError, fmt.Errorf("failed to convert command %q to UTF16", command)
+	}
+
+	var useCwd wBOOL
+	if useCWD {
+		useCwd = 1
+	}
+
+	var exitCode uint32
+
+	r1, _, _ := wslLaunchInteractive.Call(
+		uintptr(unsafe.Pointer(distroUTF16)),
+		uintptr(unsafe.Pointer(commandUTF16)),
+		uintptr(useCwd),
+		uintptr(unsafe.Pointer(&exitCode)))
+
+	if r1 != 0 {
+		return WindowsError, fmt.Errorf("failed syscall to WslLaunchInteractive")
+	}
+
+	return exitCode, nil
+}
+
+func (windowsBackend) Registry() RegistryReader {
+	return windowsRegistry{}
+}
+
+func (windowsBackend) Shutdown(ctx context.Context) error {
+	return shutdown(ctx)
+}
+
+func (windowsBackend) Terminate(ctx context.Context, distroName string) error {
+	return terminate(ctx, distroName)
+}
+
+func (windowsBackend) SetAsDefault(ctx context.Context, distroName string) error {
+	return setAsDefault(ctx, distroName)
+}
+
+func (windowsBackend) Export(ctx context.Context, distroName string, dst io.Writer, format Format) error {
+	return exportDistro(ctx, distroName, dst, format)
+}
+
+func (windowsBackend) Import(ctx context.Context, distroName string, installDir string, source string, format Format, version uint8) error {
+	return importDistro(ctx, distroName, installDir, source, format, version)
+}
+
+func (windowsBackend) ImportInPlace(ctx context.Context, distroName string, vhdx string) error {
+	return importDistroInPlace(ctx, distroName, vhdx)
+}
+
+func (windowsBackend) States(ctx context.Context) (map[string]State, error) {
+	// wsl.exe fails when there are no distros to list
+	distros, err := registeredDistros(windowsRegistry{})
+	if err != nil {
+		return nil, err
+	}
+	if len(distros) == 0 {
+		return map[string]State{}, nil
+	}
+
+	return listVerbose(ctx)
+}
+
+func (windowsBackend) SetVersion(ctx context.Context, distroName string, version uint8, progress io.Writer) error {
+	return setVersion(ctx, distroName, version, progress)
+}
+
+func (windowsBackend) SetDefaultEnvironment(guid string, env []string) error {
+	return windowsRegistry{}.setStringsValue(guid, "DefaultEnvironment", env)
+}
+
+func (windowsBackend) SetDistributionName(guid string, name string) error {
+	return windowsRegistry{}.setStringValue(guid, "DistributionName", name)
+}
+
+func (windowsBackend) SetBasePath(guid string, basePath string) error {
+	return windowsRegistry{}.setStringValue(guid, "BasePath", basePath)
+}
+
+// windowsProcess is a wrapper around the Windows process spawned by WslLaunch.
+type windowsProcess struct {
+	handle     syscall.Handle // The windows handle to the WSL process
+	exitStatus *uint32        // Exit status of the process. Cached because it cannot be read after the preocess is closed.
+
+	distroName string // The distro the process was launched into
+	marker     string // Value of processMarkerVar in the environment of the Linux processes. Empty for interactive shells.
+}
+
+// Wait waits for the process to exit and closes its handle.
+func (p *windowsProcess) Wait() (uint32, error) {
+	event, statusError := syscall.WaitForSingleObject(p.handle, syscall.INFINITE)
+	if statusError != nil {
+		return WindowsError, fmt.Errorf("failed syscall to WaitForSingleObject: %v", statusError)
+	}
+	if event != syscall.WAIT_OBJECT_0 {
+		return WindowsError, fmt.Errorf("failed syscall to WaitForSingleObject, non-zero exit status %d", event)
+	}
+
+	// NOTE(brainman): It seems that sometimes process is not dead
+	// when WaitForSingleObject returns. But we do not know any
+	// other way to wait for it. Sleeping for a while seems to do
+	// the trick sometimes.
+	// See https://golang.org/issue/25965 for details.
+	time.Sleep(5 * time.Millisecond)
+
+	status, statusError := p.status()
+	ok := statusError == nil && status == 0
+
+	if err := syscall.CloseHandle(p.handle); !ok && err != nil {
+		return WindowsError, err
+	}
+	p.exitStatus = &status
+	return status, statusError
+}
+
+// Kill gets the exit status before closing the process, without checking
+// if it has finished or not.
+func (p *windowsProcess) Kill() error {
+	status, err := p.status()
+	p.exitStatus = nil
+	if err == nil {
+		p.exitStatus = &status
+	}
+	return syscall.TerminateProcess(p.handle, ActiveProcess)
+}
+
+// Interrupt sends SIGTERM to the Linux processes via wsl.exe, as the Windows process
+// cannot deliver signals.
+func (p *windowsProcess) Interrupt() error {
+	return interruptProcesses(p.distroName, "", p.marker)
+}
+
+// status querries Windows for the process' status.
+func (p *windowsProcess) status() (exit uint32, err error) {
+	// Retrieving from cache in case the process has been closed
+	if p.exitStatus != nil {
+		return *p.exitStatus, nil
+	}
+
+	err = syscall.GetExitCodeProcess(p.handle, &exit)
+	if err != nil {
+		return WindowsError, fmt.Errorf("failed to retrieve exit status: %v", err)
+	}
+	return exit, nil
+}
+
+// processEnvVariables takes the (**char, length) obtained from Win32's API and returs a
+// map[variableName]variableValue. It also deallocates each of the *char strings as well
+// as the **char array.
+func processEnvVariables(cStringArray **char, len uint64) map[string]string {
+	stringPtrs := unsafe.Slice(cStringArray, len)
+
+	env := make(chan struct {
+		key   string
+		value string
+	})
+
+	wg := sync.WaitGroup{}
+	for _, cStr := range stringPtrs {
+		cStr := cStr
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			goStr := stringCtoGo(cStr, 32768)
+			idx := strings.Index(goStr, "=")
+			env <- struct {
+				key   string
+				value string
+			}{
+				key:   strings.Clone(goStr[:idx]),
+				value: strings.Clone(goStr[idx+1:]),
+			}
+			coTaskMemFree(unsafe.Pointer(cStr))
+		}()
+	}
+
+	// Cleanup
+	go func() {
+		wg.Wait()
+		coTaskMemFree(unsafe.Pointer(cStringArray))
+		close(env)
+	}()
+
+	// Collecting results
+	m := map[string]string{}
+
+	for kv := range env {
+		m[kv.key] = kv.value
+	}
+
+	return m
+}
+
+// stringCtoGo converts a null-terminated *char into a string
+// maxlen is the max distance that will searched. It is meant
+// to prevent or mitigate buffer overflows.
+func stringCtoGo(cString *char, maxlen uint64) (goString string) {
+	size := strnlen(cString, maxlen)
+	return string(unsafe.Slice(cString, size))
+}
+
+// strnlen finds the null terminator to determine *char length.
+// The null terminator itself is not counted towards the length.
+// maxlen is the max distance that will searched. It is meant to
+// prevent or mitigate buffer overflows.
+func strnlen(ptr *char, maxlen uint64) (length uint64) {
+	length = 0
+	for ; *ptr != 0 && length <= maxlen; ptr = charNext(ptr) {
+		length++
+	}
+	return length
+}
+
+// charNext advances *char by one position.
+func charNext(ptr *char) *char {
+	return (*char)(unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + unsafe.Sizeof(char(0))))
+}
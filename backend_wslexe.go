@@ -0,0 +1,286 @@
+package wsl
+
+// This file contains a Backend that launches commands via wsl.exe rather than wslapi.dll.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// wslExeBackend launches commands via wsl.exe and delegates everything else to the embedded Backend.
+type wslExeBackend struct {
+	Backend
+}
+
+// NewWSLExeBackend returns a Backend that launches commands (see Cmd and Shell) via wsl.exe
+// instead of WslLaunch and WslLaunchInteractive, which are not available in some contexts,
+// such as services running in session 0. Everything else is delegated to base, or to the
+// default backend if base is nil. Use it with SetBackend.
+//
+// As with WslLaunch, commands are run by the default shell of the user. Their output
+// is passed through untouched, whereas the messages of wsl.exe itself are requested in UTF-8
+// (see WSL_UTF8) and decoded from UTF-16 if need be.
+func NewWSLExeBackend(base Backend) Backend {
+	if base == nil {
+		base = defaultBackend()
+	}
+	return wslExeBackend{Backend: base}
+}
+
+// Launch runs the command via wsl.exe, as the default user of the distro.
+func (wslExeBackend) Launch(distroName string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error) {
+	return launchDistro(distroName, "", command, useCWD, stdin, stdout, stderr)
+}
+
+// LaunchAsUser runs the command via wsl.exe, as the specified user.
+func (wslExeBackend) LaunchAsUser(distroName string, user string, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error) {
+	return launchDistro(distroName, user, command, useCWD, stdin, stdout, stderr)
+}
+
+// LaunchExec runs the program via wsl.exe --exec, without any shell.
+func (wslExeBackend) LaunchExec(distroName string, user string, dir string, argv []string, stdin, stdout, stderr *os.File) (Process, error) {
+	return launchExec(distroName, user, dir, argv, stdin, stdout, stderr)
+}
+
+// LaunchInteractive runs the command via wsl.exe with the standard streams of the current
+// process, and waits for it to finish. If the command is empty, the default shell is started.
+func (wslExeBackend) LaunchInteractive(distroName string, command string, useCWD bool) (uint32, error) {
+	cmd := wslExeCommand(context.Background(), launchArgs(distroName, "", command, useCWD)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// The messages of wsl.exe are already on the console, so they are not collected
+	return wslExeExitStatus(cmd.Run(), nil)
+}
+
+// launchArgs returns the arguments to launch a command in a distro via wsl.exe. If the user is
+// empty, the command runs as the default user. The command is run by the default shell of the
+// user, which is started on its own if the command is empty.
+//
+// They are analogous to
+//
+//	`wsl.exe --distribution <distroName> [--user <user>] [--cd ~] [-- <command>]`
+func launchArgs(distroName, user, command string, useCWD bool) []string {
+	args := execArgs(distroName, user, launchDir(useCWD), nil)
+	if command != "" {
+		args = append(args, "--", command)
+	}
+	return args
+}
+
+// execArgs returns the arguments to run a program in a distro via wsl.exe, without any shell. If
+// the user is empty, the program runs as the default user. If the directory is empty, it runs in
+// the current working directory. If argv is empty, the default shell is started instead.
+//
+// They are analogous to
+//
+//	`wsl.exe --distribution <distroName> [--user <user>] [--cd <dir>] [--exec <argv>...]`
+func execArgs(distroName, user, dir string, argv []string) []string {
+	args := []string{"--distribution", distroName}
+	if user != "" {
+		args = append(args, "--user", user)
+	}
+	if dir != "" {
+		args = append(args, "--cd", dir)
+	}
+	if len(argv) > 0 {
+		// --exec passes the arguments as they are, while otherwise wsl.exe re-joins them into a command line
+		args = append(args, "--exec")
+		args = append(args, argv...)
+	}
+	return args
+}
+
+// launchDir returns the directory that wsl.exe is asked to launch commands in: the home
+// directory of the user, or the current working directory if useCWD is set.
+func launchDir(useCWD bool) string {
+	if useCWD {
+		return ""
+	}
+	return "~"
+}
+
+// launchDistro starts a command in a distro via wsl.exe, without waiting for it to finish (see
+// launchArgs). The process has its own copy of the standard streams, so the caller may close them
+// once it has started.
+func launchDistro(distroName, user, command string, useCWD bool, stdin, stdout, stderr *os.File) (Process, error) {
+	return startWSLExe(distroName, user, launchArgs(distroName, user, command, useCWD), stdin, stdout, stderr)
+}
+
+// launchExec is like launchDistro, but the program runs without any shell (see execArgs).
+func launchExec(distroName, user, dir string, argv []string, stdin, stdout, stderr *os.File) (Process, error) {
+	return startWSLExe(distroName, user, execArgs(distroName, user, dir, argv), stdin, stdout, stderr)
+}
+
+// startWSLExe starts wsl.exe with the arguments to launch something in the distro as the user.
+func startWSLExe(distroName, user string, args []string, stdin, stdout, stderr *os.File) (Process, error) {
+	marker, err := newProcessMarker()
+	if err != nil {
+		return nil, err
+	}
+
+	// The process is killed via Process.Kill rather than with a context
+	cmd := wslExeCommand(context.Background(), args...)
+	cmd.Env = markEnvironment(cmd.Env, marker)
+
+	// The output of the command and the messages of wsl.exe go into the same streams, so the
+	// start and the end of each are kept in case wsl.exe fails itself (see wslExeExitStatus).
+	// If stdout and stderr are the same file, they share a single stream to keep their order.
+	outTail, err := newStreamTail(stdout)
+	if err != nil {
+		return nil, fmt.Errorf("could not duplicate stdout: %v", err)
+	}
+	errTail := outTail
+	if stderr != stdout {
+		if errTail, err = newStreamTail(stderr); err != nil {
+			outTail.Close()
+			return nil, fmt.Errorf("could not duplicate stderr: %v", err)
+		}
+	}
+
+	cmd.Stdin = stdin
+	cmd.Stdout = outTail
+	cmd.Stderr = errTail
+
+	closeTails := func() {
+		outTail.Close()
+		if errTail != outTail {
+			errTail.Close()
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		closeTails()
+		return nil, fmt.Errorf("could not start wsl.exe: %v", err)
+	}
+
+	p := &wslExeProcess{
+		process:    cmd.Process,
+		distroName: distroName,
+		user:       user,
+		marker:     marker,
+		done:       make(chan struct{}),
+	}
+
+	go func() {
+		err := cmd.Wait()
+		closeTails()
+
+		output := outTail.Bytes()
+		if errTail != outTail {
+			output = append(output, errTail.Bytes()...)
+		}
+		p.exitCode, p.err = wslExeExitStatus(err, output)
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// streamTail writes into a copy of one of the caller's streams, and keeps the start and the end
+// of what is written so that the messages of wsl.exe can be parsed if it fails.
+type streamTail struct {
+	prefixSuffixSaver
+	file *os.File // Copy of the caller's file. Nil if there is none.
+}
+
+// newStreamTail returns a streamTail that writes into a copy of f, so that the caller may close
+// f once the process has started. If f is nil, the output is only kept.
+func newStreamTail(f *os.File) (*streamTail, error) {
+	t := &streamTail{prefixSuffixSaver: prefixSuffixSaver{N: 32 << 10}}
+	if f == nil {
+		return t, nil
+	}
+
+	dup, err := dupFile(f)
+	if err != nil {
+		return nil, err
+	}
+	t.file = dup
+	return t, nil
+}
+
+// Write writes p into the copy of the caller's file and keeps it.
+func (t *streamTail) Write(p []byte) (int, error) {
+	if t.file != nil {
+		if n, err := t.file.Write(p); err != nil {
+			return n, err
+		}
+	}
+	return t.prefixSuffixSaver.Write(p)
+}
+
+// Close closes the copy of the caller's file. What was written is still kept.
+func (t *streamTail) Close() error {
+	if t.file == nil {
+		return nil
+	}
+	return t.file.Close()
+}
+
+// wslExeProcess is the Process that wraps a running wsl.exe.
+type wslExeProcess struct {
+	process *os.Process
+	killed  int32 // Set to 1 when the process is killed. Accessed atomically.
+
+	distroName string // The distro the process was launched into
+	user       string // The user the process runs as. Empty for the default user.
+	marker     string // Value of processMarkerVar in the environment of the Linux processes
+
+	done     chan struct{} // Closed when the process exits
+	exitCode uint32        // Only valid once done is closed
+	err      error         // Only valid once done is closed
+}
+
+// Wait waits for wsl.exe to exit. If wsl.exe fails rather than the command, the error
+// is a *WSLExeError.
+func (p *wslExeProcess) Wait() (uint32, error) {
+	<-p.done
+
+	if atomic.LoadInt32(&p.killed) == 1 {
+		return ActiveProcess, nil
+	}
+	return p.exitCode, p.err
+}
+
+// Kill kills wsl.exe. It has no effect if the process has already exited.
+func (p *wslExeProcess) Kill() error {
+	atomic.StoreInt32(&p.killed, 1)
+	err := p.process.Kill()
+	if errors.Is(err, os.ErrProcessDone) {
+		atomic.StoreInt32(&p.killed, 0)
+		return nil
+	}
+	return err
+}
+
+// Interrupt sends SIGTERM to the Linux processes via another wsl.exe.
+func (p *wslExeProcess) Interrupt() error {
+	return interruptProcesses(p.distroName, p.user, p.marker)
+}
+
+// wslExeExitStatus converts the error from running a command via wsl.exe into the exit code
+// of the command. wsl.exe exits with the exit code of the Linux command, which ranges from 0
+// to 255, except when it fails itself. In that case, its output is parsed into a *WSLExeError.
+func wslExeExitStatus(err error, output []byte) (uint32, error) {
+	if err == nil {
+		return 0, nil
+	}
+
+	// Usually an *exec.ExitError
+	var exitErr interface{ ExitCode() int }
+	if !errors.As(err, &exitErr) {
+		return WindowsError, fmt.Errorf("could not run wsl.exe: %v", err)
+	}
+
+	code := exitErr.ExitCode()
+	if code < 0 || code > 255 {
+		return WindowsError, newWSLExeError(err, output)
+	}
+
+	return uint32(code), nil
+}
@@ -0,0 +1,310 @@
+package wsl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaunchArgs(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		user    string
+		command string
+		useCWD  bool
+
+		want []string
+	}{
+		"Default user in the home directory":    {command: "echo 'Hello'", want: []string{"--distribution", "SomeDistro", "--cd", "~", "--", "echo 'Hello'"}},
+		"Default user in the current directory": {command: "echo 'Hello'", useCWD: true, want: []string{"--distribution", "SomeDistro", "--", "echo 'Hello'"}},
+		"Specific user":                         {user: "ubuntu", command: "whoami", want: []string{"--distribution", "SomeDistro", "--user", "ubuntu", "--cd", "~", "--", "whoami"}},
+		"Default shell without command":         {want: []string{"--distribution", "SomeDistro", "--cd", "~"}},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := launchArgs("SomeDistro", tc.user, tc.command, tc.useCWD)
+			require.Equal(t, tc.want, got, "Unexpected arguments for wsl.exe")
+		})
+	}
+}
+
+func TestLaunchDistro(t *testing.T) {
+	testCases := map[string]struct {
+		useCWD  bool
+		standIn standIn
+		kill    bool
+
+		wantArgs []string
+		wantCode uint32
+	}{
+		"in the home directory":     {standIn: standIn{stdout: "Hello"}, wantArgs: []string{"--distribution", "SomeDistro", "--user", "ubuntu", "--cd", "~", "--", "echo 'Hello'"}},
+		"in the current directory":  {useCWD: true, standIn: standIn{stdout: "Hello"}, wantArgs: []string{"--distribution", "SomeDistro", "--user", "ubuntu", "--", "echo 'Hello'"}},
+		"exit code of the command":  {standIn: standIn{exitCode: 42}, wantCode: 42},
+		"killed before it finishes": {standIn: standIn{sleep: time.Minute}, kill: true, wantCode: ActiveProcess},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			recordedArgs := useStandInWslExe(t, tc.standIn)
+
+			stdin, err := os.Open(os.DevNull)
+			require.NoError(t, err, "Setup: could not open the null device")
+			defer stdin.Close()
+
+			stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+			require.NoError(t, err, "Setup: could not create the output file")
+
+			p, err := launchDistro("SomeDistro", "ubuntu", "echo 'Hello'", tc.useCWD, stdin, stdout, stdout)
+			require.NoError(t, err, "Unexpected error launching a command")
+
+			// The process must not depend on the caller's copy of the standard streams
+			stdout.Close()
+
+			if tc.kill {
+				time.Sleep(100 * time.Millisecond)
+				require.NoError(t, p.Kill(), "Unexpected error killing the process")
+			}
+
+			code, err := p.Wait()
+			require.NoError(t, err, "Unexpected error waiting for the process")
+			require.Equal(t, tc.wantCode, code, "Unexpected exit code")
+
+			if tc.wantArgs == nil {
+				return
+			}
+			require.Equal(t, tc.wantArgs, recordedArgs(), "Unexpected arguments passed to wsl.exe")
+
+			out, err := os.ReadFile(stdout.Name())
+			require.NoError(t, err, "Could not read the output of the process")
+			require.Equal(t, tc.standIn.stdout, string(out), "Output of wsl.exe should be written into the standard output")
+		})
+	}
+}
+
+func TestStreamTail(t *testing.T) {
+	t.Parallel()
+
+	messages := "There is no distribution with the supplied name.\r\nError code: Wsl/Service/WSL_E_DISTRO_NOT_FOUND\r\n"
+
+	testCases := map[string]struct {
+		noFile bool
+	}{
+		"Into the caller's file": {},
+		"Without any file":       {noFile: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var f *os.File
+			if !tc.noFile {
+				var err error
+				f, err = os.Create(filepath.Join(t.TempDir(), "stderr"))
+				require.NoError(t, err, "Setup: could not create the output file")
+			}
+
+			tail, err := newStreamTail(f)
+			require.NoError(t, err, "Unexpected error creating the stream")
+
+			// The stream must not depend on the caller's copy of the file
+			if f != nil {
+				f.Close()
+			}
+
+			_, err = io.WriteString(tail, messages)
+			require.NoError(t, err, "Unexpected error writing into the stream")
+			require.NoError(t, tail.Close(), "Unexpected error closing the stream")
+
+			require.Equal(t, messages, string(tail.Bytes()), "The stream should keep what is written")
+
+			_, err = wslExeExitStatus(exitCodeError(4294967295), tail.Bytes())
+			var target *WSLExeError
+			require.ErrorAs(t, err, &target, "Expected a WSLExeError")
+			require.Equal(t, "There is no distribution with the supplied name.", target.Message, "Unexpected message")
+			require.Equal(t, "Wsl/Service/WSL_E_DISTRO_NOT_FOUND", target.Code, "Unexpected error code")
+
+			if f == nil {
+				return
+			}
+			out, err := os.ReadFile(f.Name())
+			require.NoError(t, err, "Could not read the output file")
+			require.Equal(t, messages, string(out), "The stream should be written into the caller's file")
+		})
+	}
+}
+
+// exitCodeError stands in for an *exec.ExitError with an arbitrary exit code.
+type exitCodeError int
+
+func (e exitCodeError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitCodeError) ExitCode() int { return int(e) }
+
+func TestWSLExeExitStatus(t *testing.T) {
+	t.Parallel()
+
+	utf16le := func(s string) []byte {
+		b := []byte{0xff, 0xfe}
+		for _, r := range s {
+			b = append(b, byte(r), 0)
+		}
+		return b
+	}
+
+	notFound := "There is no distribution with the supplied name.\r\nError code: Wsl/Service/WSL_E_DISTRO_NOT_FOUND\r\n"
+
+	testCases := map[string]struct {
+		err    error
+		output []byte
+
+		wantCode    uint32
+		wantMessage string
+		wantErr     bool
+	}{
+		"Success":                        {},
+		"Exit code of the command":       {err: exitCodeError(1), output: []byte("No such file or directory\n"), wantCode: 1},
+		"Highest exit code of a command": {err: exitCodeError(255), wantCode: 255},
+
+		"Error when wsl.exe fails with UTF-8 output":  {err: exitCodeError(4294967295), output: []byte(notFound), wantMessage: "There is no distribution with the supplied name.", wantErr: true},
+		"Error when wsl.exe fails with UTF-16 output": {err: exitCodeError(4294967295), output: utf16le(notFound), wantMessage: "There is no distribution with the supplied name.", wantErr: true},
+		"Error when wsl.exe cannot run":               {err: errors.New("executable file not found in %PATH%"), wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			code, err := wslExeExitStatus(tc.err, tc.output)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success")
+				require.Equal(t, WindowsError, code, "Failures of wsl.exe should be reported as Windows errors")
+				if tc.wantMessage != "" {
+					var target *WSLExeError
+					require.ErrorAs(t, err, &target, "Expected a WSLExeError")
+					require.Equal(t, tc.wantMessage, target.Message, "Unexpected error message")
+					require.Equal(t, "Wsl/Service/WSL_E_DISTRO_NOT_FOUND", target.Code, "Unexpected error code")
+				}
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			require.Equal(t, tc.wantCode, code, "Unexpected exit code")
+		})
+	}
+}
+
+func TestWSLExeBackendCommand(t *testing.T) {
+	fake := NewFakeBackend()
+	t.Cleanup(SetBackend(NewWSLExeBackend(fake)))
+
+	rootfs := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	require.NoError(t, os.WriteFile(rootfs, []byte{}, 0600), "Setup: could not create fake rootfs")
+
+	d := Distro{Name: "SomeDistro"}
+	require.NoError(t, d.Register(rootfs), "Setup: could not register distro")
+
+	testCases := map[string]struct {
+		standIn standIn
+		run     func(*Cmd) (string, error)
+
+		want     string
+		wantCode uint32
+		wantErr  bool
+	}{
+		"Output": {
+			standIn: standIn{stdout: "Hello\n", stderr: "warning\n"},
+			run:     func(c *Cmd) (string, error) { out, err := c.Output(); return string(out), err },
+			want:    "Hello\n",
+		},
+		"CombinedOutput": {
+			standIn: standIn{stdout: "Hello\n", stderr: "warning\n"},
+			run:     func(c *Cmd) (string, error) { out, err := c.CombinedOutput(); return string(out), err },
+			want:    "Hello\nwarning\n",
+		},
+		"Non-UTF-8 output is passed through": {
+			standIn: standIn{stdout: "\xff\xfeH\x00i\x00"},
+			run:     func(c *Cmd) (string, error) { out, err := c.Output(); return string(out), err },
+			want:    "\xff\xfeH\x00i\x00",
+		},
+		"Stdin": {
+			standIn: standIn{echo: true},
+			run: func(c *Cmd) (string, error) {
+				c.Stdin = strings.NewReader("from stdin")
+				out, err := c.Output()
+				return string(out), err
+			},
+			want: "from stdin",
+		},
+		"StdoutPipe": {
+			standIn: standIn{stdout: "piped"},
+			run: func(c *Cmd) (string, error) {
+				r, err := c.StdoutPipe()
+				if err != nil {
+					return "", err
+				}
+				if err := c.Start(); err != nil {
+					return "", err
+				}
+				out, err := io.ReadAll(r)
+				if err != nil {
+					return "", err
+				}
+				return string(out), c.Wait()
+			},
+			want: "piped",
+		},
+		"Exit code of the command": {
+			standIn:  standIn{stderr: "failure\n", exitCode: 3},
+			run:      func(c *Cmd) (string, error) { out, err := c.Output(); return string(out), err },
+			wantCode: 3,
+			wantErr:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			recordedArgs := useStandInWslExe(t, tc.standIn)
+
+			got, err := tc.run(d.Command(context.Background(), "echo 'Hello'"))
+			if tc.wantErr {
+				var target *ExitError
+				require.ErrorAs(t, err, &target, "Expected an ExitError")
+				require.Equal(t, tc.wantCode, target.Code, "Unexpected exit code")
+				return
+			}
+			require.NoError(t, err, "Unexpected error running the command")
+			require.Equal(t, tc.want, got, "Unexpected output")
+			require.Equal(t, []string{"--distribution", "SomeDistro", "--cd", "~", "--", "echo 'Hello'"}, recordedArgs(), "Unexpected arguments passed to wsl.exe")
+		})
+	}
+
+	t.Run("Exec", func(t *testing.T) {
+		recordedArgs := useStandInWslExe(t, standIn{stdout: "$HOME\n"})
+
+		cmd := d.CommandArgs(context.Background(), "printf", `%s\n`, "$HOME")
+		cmd.Exec = true
+		cmd.Dir = "/tmp"
+		cmd.Env = []string{"A=1"}
+
+		out, err := cmd.Output()
+		require.NoError(t, err, "Unexpected error running the command")
+		require.Equal(t, "$HOME\n", string(out), "Unexpected output")
+		require.Equal(t, []string{"--distribution", "SomeDistro", "--cd", "/tmp", "--exec", "env", "A=1", "printf", `%s\n`, "$HOME"}, recordedArgs(), "The arguments should be passed to wsl.exe without a shell")
+	})
+}
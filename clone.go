@@ -0,0 +1,107 @@
+package wsl
+
+// This file contains utilities to create copies of a distro.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type cloneOptions struct {
+	installDir string
+}
+
+// WithInstallDir is an optional parameter for Clone that sets the directory where the
+// filesystem of the clone is stored. By default, it is stored in a directory named after
+// the clone, next to the directory of the original distro.
+func WithInstallDir(installDir string) func(*cloneOptions) {
+	return func(o *cloneOptions) {
+		o.installDir = installDir
+	}
+}
+
+// Clone creates a new distro named newName with a copy of the filesystem of the distro. The
+// clone has the same WSL version, default user, and interop, path appending and drive mounting
+// settings as the original.
+//
+// The filesystem is copied by exporting the distro into a temporary tarball, and importing
+// it back. If a step after the import fails, the clone is unregistered. If the import itself
+// fails, nothing is unregistered: a distro left behind with that name cannot be told apart from
+// one registered by someone else in the meantime, so it is up to the caller to remove it.
+func (d Distro) Clone(ctx context.Context, newName string, opts ...func(*cloneOptions)) (clone Distro, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error cloning %q into %q: %w", d.Name, newName, err)
+		}
+	}()
+
+	clone = Distro{Name: newName}
+
+	conf, err := d.GetConfiguration()
+	if err != nil {
+		return clone, err
+	}
+
+	options := cloneOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.installDir == "" {
+		props, err := d.Properties()
+		if err != nil {
+			return clone, err
+		}
+		if props.BasePath == "" {
+			return clone, errors.New("could not determine the install directory: use WithInstallDir")
+		}
+		options.installDir = filepath.Join(filepath.Dir(props.BasePath), newName)
+	}
+
+	if err := clone.checkNameAvailable(); err != nil {
+		return clone, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "wsl-clone-*")
+	if err != nil {
+		return clone, fmt.Errorf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tarball := filepath.Join(tmpDir, "rootfs.tar")
+	if err := d.Export(ctx, tarball); err != nil {
+		return clone, err
+	}
+
+	if err := clone.Import(ctx, tarball, options.installDir, WithVersion(conf.WSLVersion)); err != nil {
+		// No distro was created by this clone as far as we can tell, so none is cleaned up
+		return clone, err
+	}
+
+	// The copy is pinned so that only the distro created here can be cleaned up
+	created := clone
+	if err := created.Pin(); err != nil {
+		return clone, err
+	}
+
+	if err := clone.configure(conf); err != nil {
+		return clone, cleanUpClone(created, fmt.Errorf("could not configure the clone: %w", err))
+	}
+
+	return clone, nil
+}
+
+// cleanUpClone unregisters a clone that could not be completed, if it is registered. Any error
+// doing so is added to err, which is returned.
+func cleanUpClone(clone Distro, err error) error {
+	if r, e := clone.IsRegistered(); e != nil || !r {
+		return err
+	}
+	if e := clone.Unregister(); e != nil {
+		return fmt.Errorf("%w. Could not clean up the clone: %v", err, e)
+	}
+	return err
+}
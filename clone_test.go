@@ -0,0 +1,123 @@
+package wsl_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendClone(t *testing.T) {
+	fake := useFakeBackend(t)
+	ctx := context.Background()
+
+	golden := wsl.Distro{Name: "FakeGolden"}
+	_, err := golden.Clone(ctx, "FakeClone")
+	require.Error(t, err, "Unexpected success cloning an unregistered distro")
+
+	require.NoError(t, golden.Register(fakeRootFs(t)), "Setup: could not register fake distro")
+	require.NoError(t, golden.DefaultUID(1000), "Setup: could not set DefaultUID")
+	require.NoError(t, golden.InteropEnabled(false), "Setup: could not set InteropEnabled")
+	require.NoError(t, golden.DriveMountingEnabled(false), "Setup: could not set DriveMountingEnabled")
+
+	goldenProps, err := golden.Properties()
+	require.NoError(t, err, "Setup: could not get the properties of the fake distro")
+	goldenConf, err := golden.GetConfiguration()
+	require.NoError(t, err, "Setup: could not get the configuration of the fake distro")
+
+	clone, err := golden.Clone(ctx, "FakeClone")
+	require.NoError(t, err, "Unexpected error cloning a distro")
+	require.Equal(t, "FakeClone", clone.Name, "Unexpected name of the clone")
+
+	cloneConf, err := clone.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration of the clone")
+	require.Equal(t, goldenConf, cloneConf, "The clone should have the same configuration as the original")
+
+	cloneProps, err := clone.Properties()
+	require.NoError(t, err, "Unexpected error getting the properties of the clone")
+	require.Equal(t, filepath.Join(filepath.Dir(goldenProps.BasePath), "FakeClone"), cloneProps.BasePath, "Unexpected default install directory")
+	require.NotEqual(t, goldenProps.GUID, cloneProps.GUID, "The clone should be a different distro")
+
+	installDir := filepath.Join(t.TempDir(), "elsewhere")
+	clone, err = golden.Clone(ctx, "FakeCloneElsewhere", wsl.WithInstallDir(installDir))
+	require.NoError(t, err, "Unexpected error cloning a distro into a custom install directory")
+	cloneProps, err = clone.Properties()
+	require.NoError(t, err, "Unexpected error getting the properties of the clone")
+	require.Equal(t, installDir, cloneProps.BasePath, "Unexpected install directory")
+
+	_, err = golden.Clone(ctx, "FakeClone")
+	require.Error(t, err, "Unexpected success cloning into the name of an existing distro")
+	reg, err := (&wsl.Distro{Name: "FakeClone"}).IsRegistered()
+	require.NoError(t, err, "Unexpected error checking if the existing distro is registered")
+	require.True(t, reg, "A failed clone should not remove an existing distro")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = golden.Clone(cancelled, "FakeCancelledClone")
+	require.ErrorIs(t, err, context.Canceled, "Expected the clone to be cancelled")
+
+	// Another distro takes the name of the clone while it is being created, so it must be left alone
+	restore := wsl.SetBackend(racingImportBackend{FakeBackend: fake, rootfs: fakeRootFs(t)})
+	_, err = golden.Clone(ctx, "FakeTakenClone")
+	restore()
+	require.Error(t, err, "Unexpected success cloning into a name taken during the clone")
+	reg, err = (&wsl.Distro{Name: "FakeTakenClone"}).IsRegistered()
+	require.NoError(t, err, "Unexpected error checking if the other distro is registered")
+	require.True(t, reg, "A failed clone should not remove a distro registered by someone else")
+
+	// The import fails after registering a distro, which cannot be told apart from someone else's
+	restore = wsl.SetBackend(failingImportBackend{FakeBackend: fake, rootfs: fakeRootFs(t)})
+	_, err = golden.Clone(ctx, "FakeHalfImportedClone")
+	restore()
+	require.Error(t, err, "Unexpected success cloning a distro that cannot be imported")
+	reg, err = (&wsl.Distro{Name: "FakeHalfImportedClone"}).IsRegistered()
+	require.NoError(t, err, "Unexpected error checking if the half-imported distro is registered")
+	require.True(t, reg, "A failed import should not unregister anything")
+
+	// Configuring the clone fails, so it must be cleaned up
+	t.Cleanup(wsl.SetBackend(failingConfigBackend{fake}))
+	_, err = golden.Clone(ctx, "FakeFailedClone")
+	require.Error(t, err, "Unexpected success cloning a distro that cannot be configured")
+	reg, err = (&wsl.Distro{Name: "FakeFailedClone"}).IsRegistered()
+	require.NoError(t, err, "Unexpected error checking if the failed clone is registered")
+	require.False(t, reg, "A failed clone should be unregistered")
+}
+
+// failingConfigBackend is a FakeBackend that fails to configure distros.
+type failingConfigBackend struct {
+	*wsl.FakeBackend
+}
+
+func (failingConfigBackend) ConfigureDistribution(string, wsl.Configuration) error {
+	return errors.New("mock error")
+}
+
+// racingImportBackend is a FakeBackend where another distro is registered with the
+// same name right before importing one.
+type racingImportBackend struct {
+	*wsl.FakeBackend
+	rootfs string
+}
+
+func (b racingImportBackend) Import(ctx context.Context, distroName string, installDir string, source string, format wsl.Format, version uint8) error {
+	if err := b.FakeBackend.RegisterDistribution(distroName, b.rootfs); err != nil {
+		return err
+	}
+	return b.FakeBackend.Import(ctx, distroName, installDir, source, format, version)
+}
+
+// failingImportBackend is a FakeBackend where importing a distro registers it, then fails.
+type failingImportBackend struct {
+	*wsl.FakeBackend
+	rootfs string
+}
+
+func (b failingImportBackend) Import(ctx context.Context, distroName string, installDir string, source string, format wsl.Format, version uint8) error {
+	if err := b.FakeBackend.RegisterDistribution(distroName, b.rootfs); err != nil {
+		return err
+	}
+	return errors.New("mock error")
+}
@@ -0,0 +1,34 @@
+package wsl_test
+
+import (
+	"context"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClone(t *testing.T) {
+	golden := newTestDistro(t, jammyRootFs)
+	require.NoError(t, golden.DefaultUID(1000), "setup: could not set DefaultUID")
+	require.NoError(t, golden.PathAppended(false), "setup: could not set PathAppended")
+
+	want, err := golden.GetConfiguration()
+	require.NoError(t, err, "setup: could not get the configuration of the original distro")
+
+	clone := wsl.Distro{Name: uniqueDistroName(t)}
+	defer func() {
+		_ = cleanUpWslInstance(clone)
+	}()
+
+	clone, err = golden.Clone(context.Background(), clone.Name, wsl.WithInstallDir(t.TempDir()))
+	require.NoError(t, err, "unexpected failure in Clone")
+
+	got, err := clone.GetConfiguration()
+	require.NoError(t, err, "unexpected failure getting the configuration of the clone")
+	require.Equal(t, want.DefaultUID, got.DefaultUID, "Clone should have the same DefaultUID")
+	require.Equal(t, want.PathAppended, got.PathAppended, "Clone should have the same PathAppended")
+
+	_, err = golden.Clone(context.Background(), clone.Name, wsl.WithInstallDir(t.TempDir()))
+	require.Error(t, err, "unexpected success cloning into an existing distro")
+}
@@ -0,0 +1,49 @@
+package wsl_test
+
+import (
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendConfigure(t *testing.T) {
+	useFakeBackend(t)
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	_, err := d.Configure(wsl.Configuration{})
+	require.Error(t, err, "Unexpected success configuring an unregistered distro")
+
+	require.NoError(t, d.Register(fakeRootFs(t)), "Setup: could not register fake distro")
+
+	changed, err := d.Configure(wsl.Configuration{DefaultUID: 1000, InteropEnabled: true})
+	require.NoError(t, err, "Unexpected error calling Configure")
+	require.ElementsMatch(t, []string{"DefaultUID", "PathAppended", "DriveMountingEnabled"}, changed, "Unexpected changed fields")
+
+	c, err := d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration")
+	assert.Equal(t, uint32(1000), c.DefaultUID)
+	assert.True(t, c.InteropEnabled)
+	assert.False(t, c.PathAppended)
+	assert.False(t, c.DriveMountingEnabled)
+
+	changed, err = d.Update(func(c *wsl.Configuration) {
+		c.PathAppended = true
+		c.DefaultEnvironmentVariables["FOO"] = "bar"
+	})
+	require.Error(t, err, "Unexpected success changing the environment with Update")
+	require.Empty(t, changed, "No fields should change after a failed update")
+
+	c, err = d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration")
+	assert.False(t, c.PathAppended, "A failed update should not change any field")
+
+	changed, err = d.Update(func(c *wsl.Configuration) { c.PathAppended = true })
+	require.NoError(t, err, "Unexpected error calling Update")
+	require.Equal(t, []string{"PathAppended"}, changed, "Unexpected changed fields")
+
+	changed, err = d.Update(func(c *wsl.Configuration) {})
+	require.NoError(t, err, "Unexpected error calling Update without changes")
+	require.Empty(t, changed, "Unexpected changed fields")
+}
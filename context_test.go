@@ -0,0 +1,123 @@
+package wsl_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendCancelledContext(t *testing.T) {
+	testCases := map[string]func(ctx context.Context, d *wsl.Distro, rootfs string) error{
+		"RegisterContext":     func(ctx context.Context, d *wsl.Distro, rootfs string) error { return d.RegisterContext(ctx, rootfs) },
+		"UnregisterContext":   func(ctx context.Context, d *wsl.Distro, _ string) error { return d.UnregisterContext(ctx) },
+		"TerminateContext":    func(ctx context.Context, d *wsl.Distro, _ string) error { return d.TerminateContext(ctx) },
+		"ShutdownContext":     func(ctx context.Context, _ *wsl.Distro, _ string) error { return wsl.ShutdownContext(ctx) },
+		"SetAsDefaultContext": func(ctx context.Context, d *wsl.Distro, _ string) error { return d.SetAsDefaultContext(ctx) },
+		"ShellContext":        func(ctx context.Context, d *wsl.Distro, _ string) error { return d.ShellContext(ctx) },
+	}
+
+	for name, f := range testCases {
+		f := f
+		t.Run(name, func(t *testing.T) {
+			fake := useFakeBackend(t)
+			rootfs := fakeRootFs(t)
+
+			fake.Script("", wsl.FakeResult("", "", 0))
+			scriptSleepInfinity(fake)
+
+			first := wsl.Distro{Name: "FirstDistro"}
+			require.NoError(t, first.Register(rootfs), "Setup: could not register fake distro")
+
+			d := wsl.Distro{Name: "FakeDistro"}
+			if name != "RegisterContext" {
+				require.NoError(t, d.Register(rootfs), "Setup: could not register fake distro")
+			}
+
+			cmd := first.Command(context.Background(), "sleep infinity")
+			require.NoError(t, cmd.Start(), "Setup: could not start command")
+
+			before, err := wsl.RegisteredDistroInfo()
+			require.NoError(t, err, "Setup: could not take a snapshot of the distros")
+			def, err := wsl.DefaultDistro()
+			require.NoError(t, err, "Setup: could not get the default distro")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err = f(ctx, &d, rootfs)
+			require.ErrorIs(t, err, context.Canceled, "Expected the cancelled context's error")
+
+			after, err := wsl.RegisteredDistroInfo()
+			require.NoError(t, err, "Could not take a snapshot of the distros")
+			require.Equal(t, before, after, "Nothing should change with a cancelled context")
+
+			gotDef, err := wsl.DefaultDistro()
+			require.NoError(t, err, "Could not get the default distro")
+			require.Equal(t, def, gotDef, "The default distro should not change with a cancelled context")
+
+			require.NoError(t, first.Terminate(), "Setup: could not terminate fake distro")
+			require.Error(t, cmd.Wait(), "Setup: command should have been terminated")
+		})
+	}
+}
+
+// blockingBackend is a FakeBackend whose distros cannot be registered or unregistered
+// until release is closed, like a slow WslRegisterDistribution would.
+type blockingBackend struct {
+	*wsl.FakeBackend
+	release chan struct{}
+}
+
+func (b blockingBackend) RegisterDistribution(distroName string, rootFsPath string) error {
+	<-b.release
+	return b.FakeBackend.RegisterDistribution(distroName, rootFsPath)
+}
+
+func (b blockingBackend) UnregisterDistribution(distroName string) error {
+	<-b.release
+	return b.FakeBackend.UnregisterDistribution(distroName)
+}
+
+func TestFakeBackendAbandonedCall(t *testing.T) {
+	fake := wsl.NewFakeBackend()
+	b := blockingBackend{FakeBackend: fake, release: make(chan struct{})}
+	t.Cleanup(wsl.SetBackend(b))
+
+	rootfs := fakeRootFs(t)
+	d := wsl.Distro{Name: "FakeDistro"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := d.RegisterContext(ctx, rootfs)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "Expected RegisterContext to stop waiting after the deadline")
+
+	// The abandoned call finishes in the background
+	close(b.release)
+	require.Eventually(t, func() bool {
+		r, err := d.IsRegistered()
+		return err == nil && r
+	}, 5*time.Second, 10*time.Millisecond, "The abandoned registration should finish in the background")
+
+	require.NoError(t, d.UnregisterContext(context.Background()), "Unexpected error unregistering a distro")
+
+	// Shells block until their script returns
+	release := make(chan struct{})
+	fake.Script("wait", func(*wsl.FakeProcess) uint32 {
+		<-release
+		return 0
+	})
+	defer close(release)
+
+	other := wsl.Distro{Name: "OtherDistro"}
+	require.NoError(t, other.Register(rootfs), "Setup: could not register fake distro")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err = other.ShellContext(ctx, wsl.WithCommand("wait"))
+	require.ErrorIs(t, err, context.DeadlineExceeded, "Expected ShellContext to stop waiting after the deadline")
+}
@@ -7,43 +7,67 @@ package wsl
 // This file contains utilities to interact with a Distro and its configuration
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sort"
-	"strings"
-	"sync"
-	"syscall"
-	"unsafe"
 )
 
 // Distro is an abstraction around a WSL distro.
 type Distro struct {
 	Name string
+
+	guid string // GUID of the registry key the distro is pinned to, if any. See Pin.
 }
 
 // Terminate powers off the distro.
 // Equivalent to:
 //  wsl --terminate <distro>
 func (d Distro) Terminate() error {
-	return terminate(d.Name)
+	return d.TerminateContext(context.Background())
+}
+
+// TerminateContext is like Terminate, but wsl.exe is killed if the context is
+// cancelled before it finishes, in which case the context's error is returned.
+func (d Distro) TerminateContext(ctx context.Context) error {
+	if err := d.checkPinned(); err != nil {
+		return err
+	}
+	return backend.Terminate(ctx, d.Name)
 }
 
 // Shutdown powers off all of WSL, including all other distros.
 // Equivalent to:
 //   wsl --shutdown
 func Shutdown() error {
-	return shutdown()
+	return ShutdownContext(context.Background())
+}
+
+// ShutdownContext is like Shutdown, but wsl.exe is killed if the context is
+// cancelled before it finishes, in which case the context's error is returned.
+func ShutdownContext(ctx context.Context) error {
+	return backend.Shutdown(ctx)
 }
 
 // SetAsDefault sets a particular distribution as the default one.
 // Equivalent to:
 //   wsl --set-default <distro>
 func (d Distro) SetAsDefault() error {
-	return setAsDefault(d.Name)
+	return d.SetAsDefaultContext(context.Background())
+}
+
+// SetAsDefaultContext is like SetAsDefault, but wsl.exe is killed if the context is
+// cancelled before it finishes, in which case the context's error is returned.
+func (d Distro) SetAsDefaultContext(ctx context.Context) error {
+	if err := d.checkPinned(); err != nil {
+		return err
+	}
+	return backend.SetAsDefault(ctx, d.Name)
 }
 
 // DefaultDistro gets the current default distribution.
 func DefaultDistro() (Distro, error) {
-	n, e := defaultDistro()
+	n, e := defaultDistro(backend.Registry())
 	return Distro{Name: n}, e
 }
 
@@ -65,14 +89,15 @@ const (
 )
 
 // Configuration is the configuration of the distro.
+// The field names used when serializing it as JSON or YAML are stable.
 type Configuration struct {
-	Version                     uint8             // Type of filesystem used (lxfs vs. wslfs, relevant only to WSL1)
-	DefaultUID                  uint32            // User ID of default user
-	InteropEnabled              bool              // Whether interop with windows is enabled
-	PathAppended                bool              // Whether Windows paths are appended
-	DriveMountingEnabled        bool              // Whether drive mounting is enabled
-	undocumentedWSLVersion      uint8             // Undocumented variable. WSL1 vs. WSL2.
-	DefaultEnvironmentVariables map[string]string // Environment variables passed to the distro by default
+	Version                     uint8             `json:"version" yaml:"version"`                                         // Type of filesystem used (lxfs vs. wslfs, relevant only to WSL1)
+	DefaultUID                  uint32            `json:"defaultUID" yaml:"defaultUID"`                                   // User ID of default user
+	InteropEnabled              bool              `json:"interopEnabled" yaml:"interopEnabled"`                           // Whether interop with windows is enabled
+	PathAppended                bool              `json:"pathAppended" yaml:"pathAppended"`                               // Whether Windows paths are appended
+	DriveMountingEnabled        bool              `json:"driveMountingEnabled" yaml:"driveMountingEnabled"`               // Whether drive mounting is enabled
+	WSLVersion                  uint8             `json:"wslVersion" yaml:"wslVersion"`                                   // WSL1 vs. WSL2. It can only be changed with SetVersion.
+	DefaultEnvironmentVariables map[string]string `json:"defaultEnvironmentVariables" yaml:"defaultEnvironmentVariables"` // Environment variables passed to the distro by default
 }
 
 // DefaultUID sets the user to the one specified.
@@ -119,43 +144,92 @@ func (d *Distro) DriveMountingEnabled(value bool) error {
 	return d.configure(conf)
 }
 
-// GetConfiguration is a wrapper around Win32's WslGetDistributionConfiguration.
-// It returns a configuration object with information about the distro.
-func (d Distro) GetConfiguration() (c Configuration, e error) {
+// Configure applies all the mutable fields of the configuration in a single call:
+//   - DefaultUID
+//   - InteropEnabled
+//   - PathAppended
+//   - DriveMountingEnabled
+//
+// The rest of the fields cannot be changed this way. They are ignored if left empty,
+// otherwise they must match the current configuration. Nothing is changed if the
+// configuration is not valid. It returns the names of the fields that changed.
+func (d *Distro) Configure(config Configuration) (changed []string, err error) {
 	defer func() {
-		if e != nil {
-			e = fmt.Errorf("error in GetConfiguration: %v", e)
+		if err != nil {
+			err = fmt.Errorf("error configuring %q: %w", d.Name, err)
 		}
 	}()
-	var conf Configuration
 
-	distroUTF16, err := syscall.UTF16PtrFromString(d.Name)
+	current, err := d.GetConfiguration()
 	if err != nil {
-		return conf, fmt.Errorf("failed to convert %q to UTF16", d.Name)
+		return nil, err
 	}
 
-	var (
-		flags        wslFlags
-		envVarsBegin **char
-		envVarsLen   uint64 // size_t
-	)
+	return d.applyConfiguration(current, config)
+}
 
-	r1, _, _ := wslGetDistributionConfiguration.Call(
-		uintptr(unsafe.Pointer(distroUTF16)),
-		uintptr(unsafe.Pointer(&conf.Version)),
-		uintptr(unsafe.Pointer(&conf.DefaultUID)),
-		uintptr(unsafe.Pointer(&flags)),
-		uintptr(unsafe.Pointer(&envVarsBegin)),
-		uintptr(unsafe.Pointer(&envVarsLen)),
-	)
+// Update modifies the configuration of the distro with f, and applies all the changes in
+// a single call. See Configure for the fields that can be modified. It returns the names
+// of the fields that changed.
+func (d *Distro) Update(f func(*Configuration)) (changed []string, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error updating configuration of %q: %w", d.Name, err)
+		}
+	}()
 
-	if r1 != 0 {
-		return conf, fmt.Errorf("failed syscall to WslGetDistributionConfiguration")
+	current, err := d.GetConfiguration()
+	if err != nil {
+		return nil, err
+	}
+
+	config := current
+	config.DefaultEnvironmentVariables = make(map[string]string, len(current.DefaultEnvironmentVariables))
+	for k, v := range current.DefaultEnvironmentVariables {
+		config.DefaultEnvironmentVariables[k] = v
+	}
+	f(&config)
+
+	return d.applyConfiguration(current, config)
+}
+
+// applyConfiguration validates the mutable fields of the new configuration and applies the
+// ones that differ from the current configuration.
+func (d *Distro) applyConfiguration(current, config Configuration) (changed []string, err error) {
+	changed, err = diffConfiguration(current, config)
+	if err != nil {
+		return nil, err
 	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	next := current
+	next.DefaultUID = config.DefaultUID
+	next.InteropEnabled = config.InteropEnabled
+	next.PathAppended = config.PathAppended
+	next.DriveMountingEnabled = config.DriveMountingEnabled
 
-	conf.unpackFlags(flags)
-	conf.DefaultEnvironmentVariables = processEnvVariables(envVarsBegin, envVarsLen)
-	return conf, nil
+	if err := d.configure(next); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// GetConfiguration is a wrapper around Win32's WslGetDistributionConfiguration.
+// It returns a configuration object with information about the distro.
+func (d Distro) GetConfiguration() (c Configuration, e error) {
+	defer func() {
+		if e != nil {
+			e = fmt.Errorf("error in GetConfiguration: %w", e)
+		}
+	}()
+
+	if err := d.checkPinned(); err != nil {
+		return c, err
+	}
+
+	return backend.GetDistributionConfiguration(d.Name)
 }
 
 // String deserializes a distro and its configuration as a yaml string.
@@ -187,9 +261,9 @@ configuration:
   - InteropEnabled: %t
   - PathAppended: %t
   - DriveMountingEnabled: %t
-  - undocumentedWSLVersion: %d
+  - WSLVersion: %d
   - DefaultEnvironmentVariables:%s`, d.Name, c.Version, c.DefaultUID, c.InteropEnabled, c.PathAppended,
-		c.DriveMountingEnabled, c.undocumentedWSLVersion, fmtEnvs)
+		c.DriveMountingEnabled, c.WSLVersion, fmtEnvs)
 }
 
 // configure is a wrapper around Win32's WslConfigureDistribution.
@@ -199,27 +273,56 @@ configuration:
 //  - PathAppended
 //  - DriveMountingEnabled
 func (d *Distro) configure(config Configuration) error {
-	distroUTF16, err := syscall.UTF16PtrFromString(d.Name)
-	if err != nil {
-		return fmt.Errorf("failed to convert %q to UTF16", d.Name)
+	if err := d.checkPinned(); err != nil {
+		return err
+	}
+	return backend.ConfigureDistribution(d.Name, config)
+}
+
+// diffConfiguration returns the names of the mutable fields that differ between two
+// configurations. Immutable fields of the new configuration must either be empty or
+// match the old configuration.
+func diffConfiguration(from, to Configuration) (changed []string, err error) {
+	if to.Version != 0 && to.Version != from.Version {
+		return nil, fmt.Errorf("cannot change Version from %d to %d", from.Version, to.Version)
 	}
 
-	flags, err := config.packFlags()
-	if err != nil {
-		return err
+	if to.WSLVersion != 0 && to.WSLVersion != from.WSLVersion {
+		return nil, fmt.Errorf("cannot change WSLVersion from %d to %d: use SetVersion instead", from.WSLVersion, to.WSLVersion)
 	}
 
-	r1, _, _ := wslConfigureDistribution.Call(
-		uintptr(unsafe.Pointer(distroUTF16)),
-		uintptr(config.DefaultUID),
-		uintptr(flags),
-	)
+	if to.DefaultEnvironmentVariables != nil && !equalEnv(to.DefaultEnvironmentVariables, from.DefaultEnvironmentVariables) {
+		return nil, errors.New("cannot change DefaultEnvironmentVariables: use SetDefaultEnvironment instead")
+	}
 
-	if r1 != 0 {
-		return fmt.Errorf("failed syscall to WslConfigureDistribution")
+	if to.DefaultUID != from.DefaultUID {
+		changed = append(changed, "DefaultUID")
 	}
+	if to.InteropEnabled != from.InteropEnabled {
+		changed = append(changed, "InteropEnabled")
+	}
+	if to.PathAppended != from.PathAppended {
+		changed = append(changed, "PathAppended")
+	}
+	if to.DriveMountingEnabled != from.DriveMountingEnabled {
+		changed = append(changed, "DriveMountingEnabled")
+	}
+
+	return changed, nil
+}
 
-	return nil
+// equalEnv returns true if both sets of environment variables are the same.
+// Nil and empty sets are considered equal.
+func equalEnv(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
 }
 
 // unpackFlags examines a winWslFlags object and stores its findings in the Configuration.
@@ -239,9 +342,9 @@ func (conf *Configuration) unpackFlags(flags wslFlags) {
 		conf.DriveMountingEnabled = true
 	}
 
-	conf.undocumentedWSLVersion = 1
+	conf.WSLVersion = 1
 	if flags&flag_undocumented_WSL_VERSION != 0 {
-		conf.undocumentedWSLVersion = 2
+		conf.WSLVersion = 2
 	}
 }
 
@@ -261,85 +364,13 @@ func (conf Configuration) packFlags() (wslFlags, error) {
 		flags = flags | flag_ENABLE_DRIVE_MOUNTING
 	}
 
-	switch conf.undocumentedWSLVersion {
+	switch conf.WSLVersion {
 	case 1:
 	case 2:
 		flags = flags | flag_undocumented_WSL_VERSION
 	default:
-		return flags, fmt.Errorf("unknown WSL version %d", conf.undocumentedWSLVersion)
+		return flags, fmt.Errorf("unknown WSL version %d", conf.WSLVersion)
 	}
 
 	return flags, nil
 }
-
-// processEnvVariables takes the (**char, length) obtained from Win32's API and returs a
-// map[variableName]variableValue. It also deallocates each of the *char strings as well
-// as the **char array.
-func processEnvVariables(cStringArray **char, len uint64) map[string]string {
-	stringPtrs := unsafe.Slice(cStringArray, len)
-
-	env := make(chan struct {
-		key   string
-		value string
-	})
-
-	wg := sync.WaitGroup{}
-	for _, cStr := range stringPtrs {
-		cStr := cStr
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			goStr := stringCtoGo(cStr, 32768)
-			idx := strings.Index(goStr, "=")
-			env <- struct {
-				key   string
-				value string
-			}{
-				key:   strings.Clone(goStr[:idx]),
-				value: strings.Clone(goStr[idx+1:]),
-			}
-			coTaskMemFree(unsafe.Pointer(cStr))
-		}()
-	}
-
-	// Cleanup
-	go func() {
-		wg.Wait()
-		coTaskMemFree(unsafe.Pointer(cStringArray))
-		close(env)
-	}()
-
-	// Collecting results
-	m := map[string]string{}
-
-	for kv := range env {
-		m[kv.key] = kv.value
-	}
-
-	return m
-}
-
-// stringCtoGo converts a null-terminated *char into a string
-// maxlen is the max distance that will searched. It is meant
-// to prevent or mitigate buffer overflows.
-func stringCtoGo(cString *char, maxlen uint64) (goString string) {
-	size := strnlen(cString, maxlen)
-	return string(unsafe.Slice(cString, size))
-}
-
-// strnlen finds the null terminator to determine *char length.
-// The null terminator itself is not counted towards the length.
-// maxlen is the max distance that will searched. It is meant to
-// prevent or mitigate buffer overflows.
-func strnlen(ptr *char, maxlen uint64) (length uint64) {
-	length = 0
-	for ; *ptr != 0 && length <= maxlen; ptr = charNext(ptr) {
-		length++
-	}
-	return length
-}
-
-// charNext advances *char by one position.
-func charNext(ptr *char) *char {
-	return (*char)(unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + unsafe.Sizeof(char(0))))
-}
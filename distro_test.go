@@ -1,9 +1,12 @@
+//go:build windows
+
 package wsl_test
 
 import (
 	"context"
 	"fmt"
 	"os/exec"
+	"strings"
 	"testing"
 	"time"
 	"wsl"
@@ -23,6 +26,20 @@ func TestShutdown(t *testing.T) {
 	require.False(t, isTestLinuxProcessAlive(&d), "Process was not killed by shutting down.")
 }
 
+func TestTerminateContextCancelled(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	defer startTestLinuxProcess(t, &d)()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := d.TerminateContext(ctx)
+	require.ErrorIs(t, err, context.Canceled, "Expected the cancelled context's error")
+
+	require.True(t, isTestLinuxProcessAlive(&d), "Process was killed by a cancelled termination.")
+}
+
 func TestTerminate(t *testing.T) {
 	sampleDistro := newTestDistro(t, jammyRootFs)  // Will terminate
 	controlDistro := newTestDistro(t, jammyRootFs) // Will not terminate, used to assert other distros are unaffected
@@ -133,7 +150,7 @@ configuration:
   - InteropEnabled: true
   - PathAppended: true
   - DriveMountingEnabled: true
-  - undocumentedWSLVersion: 2
+  - WSLVersion: 2
   - DefaultEnvironmentVariables:
     - HOSTTYPE: x86_64
     - LANG: en_US.UTF-8
@@ -350,3 +367,166 @@ func TestGetConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestProperties(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	testCases := map[string]struct {
+		distroName string
+		wantErr    bool
+	}{
+		"success":               {distroName: d.Name},
+		"distro not registered": {distroName: "IAmNotRegistered", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			d := wsl.Distro{Name: tc.distroName}
+			p, err := d.Properties()
+
+			if tc.wantErr {
+				require.Error(t, err, "unexpected success in Properties")
+				return
+			}
+			require.NoError(t, err, "unexpected failure in Properties")
+			assert.Equal(t, d.Name, p.Name)
+			assert.NotEmpty(t, p.GUID)
+			assert.NotEmpty(t, p.BasePath)
+			assert.Equal(t, uint32(1), p.State)
+			assert.Equal(t, uint32(0), p.DefaultUID)
+			assert.Contains(t, p.DefaultEnvironment, "HOSTTYPE=x86_64")
+
+			all, err := wsl.RegisteredDistroProperties()
+			require.NoError(t, err, "unexpected failure in RegisteredDistroProperties")
+			assert.Contains(t, all, p, "RegisteredDistroProperties should contain the same properties as Properties")
+		})
+	}
+}
+
+func TestState(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+	require.NoError(t, d.Terminate(), "setup: could not terminate distro")
+
+	state, err := d.State()
+	require.NoError(t, err, "unexpected failure in State")
+	require.Equal(t, wsl.Stopped, state, "Terminated distro should be stopped")
+
+	cancel := startTestLinuxProcess(t, &d)
+	defer cancel()
+
+	state, err = d.State()
+	require.NoError(t, err, "unexpected failure in State")
+	require.Equal(t, wsl.Running, state, "Distro with a running process should be running")
+
+	states, err := wsl.States()
+	require.NoError(t, err, "unexpected failure in States")
+	require.Equal(t, wsl.Running, states[d.Name], "States should report the same state as State")
+
+	state, err = (&wsl.Distro{Name: "IAmNotRegistered"}).State()
+	require.NoError(t, err, "unexpected failure in State with an unregistered distro")
+	require.Equal(t, wsl.NotRegistered, state, "Unregistered distro should be NotRegistered")
+}
+
+func TestConfigure(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	changed, err := d.Configure(wsl.Configuration{DefaultUID: 0, InteropEnabled: false, PathAppended: true, DriveMountingEnabled: true})
+	require.NoError(t, err, "unexpected failure in Configure")
+	require.Equal(t, []string{"InteropEnabled"}, changed, "Unexpected changed fields")
+
+	changed, err = d.Update(func(c *wsl.Configuration) {
+		c.InteropEnabled = true
+		c.DriveMountingEnabled = false
+	})
+	require.NoError(t, err, "unexpected failure in Update")
+	require.Equal(t, []string{"InteropEnabled", "DriveMountingEnabled"}, changed, "Unexpected changed fields")
+
+	c, err := d.GetConfiguration()
+	require.NoError(t, err, "unexpected failure in GetConfiguration")
+	require.True(t, c.InteropEnabled)
+	require.False(t, c.DriveMountingEnabled)
+
+	_, err = d.Update(func(c *wsl.Configuration) { c.WSLVersion = 1 })
+	require.Error(t, err, "unexpected success changing the WSL version with Update")
+}
+
+func TestSetDefaultEnvironment(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+	ctx := context.Background()
+
+	c, err := d.GetConfiguration()
+	require.NoError(t, err, "unexpected failure in GetConfiguration")
+
+	env := c.DefaultEnvironmentVariables
+	env["GOWSL_TEST"] = "Hello, world!"
+	require.NoError(t, d.SetDefaultEnvironment(env), "unexpected failure in SetDefaultEnvironment")
+
+	c, err = d.GetConfiguration()
+	require.NoError(t, err, "unexpected failure in GetConfiguration")
+	require.Equal(t, env, c.DefaultEnvironmentVariables, "Environment was not written into the registry")
+
+	require.NoError(t, d.Terminate(), "Setup: could not terminate the distro")
+
+	out, err := d.Command(ctx, "printenv GOWSL_TEST").Output()
+	require.NoError(t, err, "unexpected failure running printenv")
+	require.Equal(t, "Hello, world!", strings.TrimSpace(string(out)), "Environment was not applied after restarting the distro")
+
+	require.Error(t, d.SetDefaultEnvironment(map[string]string{"A=B": "C"}), "unexpected success setting an invalid variable")
+}
+
+func TestWSLConf(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+	ctx := context.Background()
+
+	conf, err := d.WSLConf(ctx)
+	require.NoError(t, err, "unexpected failure reading wsl.conf")
+
+	conf.Hostname = "gowsl-test"
+	err = d.SetWSLConf(ctx, conf)
+	require.NoError(t, err, "unexpected failure writing wsl.conf")
+
+	got, err := d.WSLConf(ctx)
+	require.NoError(t, err, "unexpected failure reading wsl.conf back")
+	require.Equal(t, "gowsl-test", got.Hostname, "Hostname was not written into wsl.conf")
+	want, err := conf.Bytes()
+	require.NoError(t, err, "unexpected failure serializing wsl.conf")
+	gotBytes, err := got.Bytes()
+	require.NoError(t, err, "unexpected failure serializing wsl.conf read back")
+	require.Equal(t, string(want), string(gotBytes), "wsl.conf did not round-trip")
+
+	require.NoError(t, d.Terminate(), "Setup: could not terminate the distro")
+
+	out, err := d.Command(ctx, "hostname").Output()
+	require.NoError(t, err, "unexpected failure running hostname")
+	require.Equal(t, "gowsl-test", strings.TrimSpace(string(out)), "Hostname was not applied after restarting the distro")
+}
+
+func TestDistroInfo(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	info, err := d.Info()
+	require.NoError(t, err, "unexpected failure taking a snapshot of the distro")
+
+	config, err := d.GetConfiguration()
+	require.NoError(t, err, "unexpected failure in GetConfiguration")
+	guid, err := d.GUID()
+	require.NoError(t, err, "unexpected failure in GUID")
+
+	require.Equal(t, d.Name, info.Name, "Unexpected name in snapshot")
+	require.Equal(t, guid, info.GUID, "Unexpected GUID in snapshot")
+	require.Equal(t, config, info.Configuration, "Unexpected configuration in snapshot")
+
+	all, err := wsl.RegisteredDistroInfo()
+	require.NoError(t, err, "unexpected failure taking a snapshot of all distros")
+	for _, i := range all {
+		if i.Name != d.Name {
+			continue
+		}
+		// The state is not compared, as the distro may have stopped in between snapshots
+		require.Equal(t, info.GUID, i.GUID, "Unexpected GUID in snapshot of all distros")
+		require.Equal(t, info.Configuration, i.Configuration, "Unexpected configuration in snapshot of all distros")
+		return
+	}
+	require.Fail(t, "Snapshot of all distros should contain the distro")
+}
@@ -0,0 +1,121 @@
+package wsl
+
+// This file contains utilities to edit the environment variables passed to a distro by default.
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// SetDefaultEnvironment replaces the environment variables passed to the distro by default
+// (see Configuration.DefaultEnvironmentVariables). WslConfigureDistribution cannot change them,
+// so they are written directly into the DefaultEnvironment value of the distro's registry key.
+//
+// Variables that were already set keep their position; new ones are added at the end in
+// alphabetical order. Names cannot be empty nor contain '=', and neither names nor values
+// can contain NUL characters. The new environment is used by processes launched from then on.
+func (d *Distro) SetDefaultEnvironment(env map[string]string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("could not set the default environment of %q: %w", d.Name, err)
+		}
+	}()
+
+	for k, v := range env {
+		if err := validateEnvironmentVariable(k, v); err != nil {
+			return err
+		}
+	}
+
+	guid, err := d.GUID()
+	if err != nil {
+		return err
+	}
+
+	current, err := backend.Registry().StringsValue(guid, "DefaultEnvironment")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return backend.SetDefaultEnvironment(guid, environmentToMultiString(current, env))
+}
+
+// validateEnvironmentVariable checks that a variable can be stored as a NAME=value entry of a multi-string.
+func validateEnvironmentVariable(name, value string) error {
+	if name == "" {
+		return errors.New("environment variable with an empty name")
+	}
+	if strings.ContainsAny(name, "=\x00") {
+		return fmt.Errorf("invalid environment variable name %q: it cannot contain '=' or NUL characters", name)
+	}
+	if strings.ContainsRune(value, 0) {
+		return fmt.Errorf("invalid value for environment variable %q: it cannot contain NUL characters", name)
+	}
+	return nil
+}
+
+// environmentFromMultiString parses a list of NAME=value entries, as stored in a REG_MULTI_SZ.
+// If a variable is repeated, the last entry is the one that counts, as in mergeEnvironment.
+func environmentFromMultiString(entries []string) map[string]string {
+	env := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		k, v, _ := strings.Cut(entry, "=")
+		env[k] = v
+	}
+	return env
+}
+
+// mergeEnvironment returns a copy of env, formatted as a list of NAME=value entries, with the
+// entries of overrides on top. Variables keep the position of their first appearance, and the
+// value of their last one, as in environmentFromMultiString.
+func mergeEnvironment(env []string, overrides []string) []string {
+	merged := make([]string, 0, len(env)+len(overrides))
+	index := make(map[string]int, cap(merged))
+
+	for _, entry := range append(append([]string{}, env...), overrides...) {
+		k, _, _ := strings.Cut(entry, "=")
+		if i, ok := index[k]; ok {
+			merged[i] = entry
+			continue
+		}
+		index[k] = len(merged)
+		merged = append(merged, entry)
+	}
+
+	return merged
+}
+
+// environmentToMultiString serializes env as a list of NAME=value entries, as stored in a
+// REG_MULTI_SZ. Variables present in current keep their position, and the rest are appended
+// in alphabetical order. Entries of current that are not in env are dropped.
+func environmentToMultiString(current []string, env map[string]string) []string {
+	entries := make([]string, 0, len(env))
+	written := make(map[string]bool, len(env))
+
+	for _, entry := range current {
+		k, _, _ := strings.Cut(entry, "=")
+		v, ok := env[k]
+		if !ok || written[k] {
+			continue
+		}
+		entries = append(entries, k+"="+v)
+		written[k] = true
+	}
+
+	var added []string
+	for k := range env {
+		if !written[k] {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(added)
+
+	for _, k := range added {
+		entries = append(entries, k+"="+env[k])
+	}
+
+	return entries
+}
@@ -0,0 +1,95 @@
+package wsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentMultiString(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		current []string
+		env     map[string]string
+
+		want []string
+	}{
+		"Empty environment":             {want: []string{}},
+		"New variables are sorted":      {env: map[string]string{"B": "2", "A": "1", "C": "3"}, want: []string{"A=1", "B=2", "C=3"}},
+		"Existing variables keep order": {current: []string{"Z=0", "A=1"}, env: map[string]string{"A": "1", "Z": "26"}, want: []string{"Z=26", "A=1"}},
+		"New variables go last":         {current: []string{"Z=0"}, env: map[string]string{"A": "1", "Z": "0"}, want: []string{"Z=0", "A=1"}},
+		"Removed variables are dropped": {current: []string{"A=1", "B=2", "C=3"}, env: map[string]string{"A": "1", "C": "3"}, want: []string{"A=1", "C=3"}},
+		"Duplicates are dropped":        {current: []string{"A=1", "A=2"}, env: map[string]string{"A": "3"}, want: []string{"A=3"}},
+		"Values may contain equals":     {env: map[string]string{"OPTS": "a=b"}, want: []string{"OPTS=a=b"}},
+		"Values may be empty":           {env: map[string]string{"EMPTY": ""}, want: []string{"EMPTY="}},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := environmentToMultiString(tc.current, tc.env)
+			require.Equal(t, tc.want, got, "Unexpected multi-string")
+
+			want := tc.env
+			if want == nil {
+				want = map[string]string{}
+			}
+			require.Equal(t, want, environmentFromMultiString(got), "Environment did not round-trip")
+		})
+	}
+}
+
+func TestEnvironmentFromMultiString(t *testing.T) {
+	t.Parallel()
+
+	entries := []string{"A=1", "OPTS=a=b", "EMPTY=", "NOVALUE", "A=2"}
+	got := environmentFromMultiString(entries)
+	require.Equal(t, map[string]string{"A": "2", "OPTS": "a=b", "EMPTY": "", "NOVALUE": ""}, got, "Unexpected environment")
+
+	// Repeated variables must be resolved the same way as when launching a command
+	merged := environmentFromMultiString(mergeEnvironment(entries, nil))
+	require.Equal(t, got, merged, "Parsing and merging should agree on repeated variables")
+}
+
+func TestValidateEnvironmentVariable(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		name  string
+		value string
+
+		wantErr bool
+	}{
+		"Valid variable":            {name: "LANG", value: "C.UTF-8"},
+		"Value with equals":         {name: "OPTS", value: "a=b"},
+		"Empty value":               {name: "EMPTY"},
+		"Error on empty name":       {value: "value", wantErr: true},
+		"Error on equals in name":   {name: "A=B", wantErr: true},
+		"Error on NUL in name":      {name: "A\x00B", wantErr: true},
+		"Error on NUL in the value": {name: "A", value: "a\x00b", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateEnvironmentVariable(tc.name, tc.value)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success validating an invalid variable")
+				return
+			}
+			require.NoError(t, err, "Unexpected error validating a valid variable")
+		})
+	}
+}
+
+func TestMergeEnvironment(t *testing.T) {
+	t.Parallel()
+
+	got := mergeEnvironment([]string{"A=1", "B=2", "C=3"}, []string{"D=4", "B=20", "D=40"})
+	require.Equal(t, []string{"A=1", "B=20", "C=3", "D=40"}, got, "Unexpected merged environment")
+}
@@ -0,0 +1,94 @@
+package wsl_test
+
+import (
+	"context"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendSetDefaultEnvironment(t *testing.T) {
+	fake := useFakeBackend(t)
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	require.Error(t, d.SetDefaultEnvironment(map[string]string{"FOO": "bar"}), "Unexpected success setting the environment of an unregistered distro")
+
+	require.NoError(t, d.Register(fakeRootFs(t)), "Setup: could not register fake distro")
+
+	guid, err := d.GUID()
+	require.NoError(t, err, "Setup: could not get the GUID of the fake distro")
+
+	c, err := d.GetConfiguration()
+	require.NoError(t, err, "Setup: could not get the configuration")
+
+	env := c.DefaultEnvironmentVariables
+	delete(env, "HOSTTYPE")
+	env["TERM"] = "dumb"
+	env["FOO"] = "bar"
+	env["EMPTY"] = ""
+	require.NoError(t, d.SetDefaultEnvironment(env), "Unexpected error setting the default environment")
+
+	got, err := fake.Registry().StringsValue(guid, "DefaultEnvironment")
+	require.NoError(t, err, "Unexpected error reading the environment from the registry")
+	require.Equal(t, []string{
+		"LANG=en_US.UTF-8",
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/usr/games:/usr/local/games",
+		"TERM=dumb",
+		"EMPTY=",
+		"FOO=bar",
+	}, got, "Existing variables should keep their order, and new ones should be appended")
+
+	c, err = d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration")
+	require.Equal(t, env, c.DefaultEnvironmentVariables, "The configuration should reflect the new environment")
+
+	for name, env := range map[string]map[string]string{
+		"Error on empty name":       {"": "value"},
+		"Error on name with equals": {"FOO=BAR": "value"},
+		"Error on name with NUL":    {"FOO\x00": "value"},
+		"Error on value with NUL":   {"FOO": "val\x00ue"},
+	} {
+		require.Error(t, d.SetDefaultEnvironment(env), "%s: Unexpected success setting an invalid environment", name)
+	}
+
+	c, err = d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration")
+	require.Equal(t, env, c.DefaultEnvironmentVariables, "An invalid environment should not be written")
+
+	require.NoError(t, d.SetDefaultEnvironment(nil), "Unexpected error clearing the default environment")
+	c, err = d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration")
+	require.Empty(t, c.DefaultEnvironmentVariables, "The environment should be empty after clearing it")
+}
+
+func TestFakeBackendCommandEnv(t *testing.T) {
+	fake := useFakeBackend(t)
+
+	d := registerFakeDistro(t, "FakeDistro")
+	require.NoError(t, d.SetDefaultEnvironment(map[string]string{"LANG": "C.UTF-8", "TERM": "xterm"}), "Setup: could not set the default environment")
+
+	fake.Script("exec env 'TERM=dumb' 'FOO=bar' /bin/sh -c 'cd -- /tmp || exit 1\nprintenv'", wsl.FakeResult("wrapped\n", "", 0))
+
+	cmd := d.Command(context.Background(), "printenv")
+	require.Equal(t, []string{"LANG=C.UTF-8", "TERM=xterm"}, cmd.Environ(), "Environ should return the default environment of the distro")
+
+	cmd.Env = []string{"TERM=dumb", "FOO=bar"}
+	cmd.Dir = "/tmp"
+	require.Equal(t, []string{"LANG=C.UTF-8", "TERM=dumb", "FOO=bar"}, cmd.Environ(), "Env should be added to the default environment")
+
+	out, err := cmd.Output()
+	require.NoError(t, err, "Unexpected error running a command with Env and Dir")
+	require.Equal(t, "wrapped\n", string(out), "Unexpected command line")
+
+	cmd = d.Command(context.Background(), "printenv")
+	cmd.Env = []string{"FOO=bar"}
+	cmd.ReplaceEnv = true
+	require.Equal(t, []string{"FOO=bar"}, cmd.Environ(), "Env should replace the default environment")
+
+	cmd = d.Command(context.Background(), "printenv")
+	cmd.Env = []string{"NOVALUE"}
+	err = cmd.Run()
+	require.Error(t, err, "Unexpected success running a command with an invalid environment")
+	require.NotErrorIs(t, err, wsl.ExitError{}, "An invalid environment should be detected before launching the command")
+}
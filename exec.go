@@ -11,9 +11,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
-	"syscall"
 	"time"
-	"unsafe"
 
 	"github.com/0xrawsec/golang-utils/log"
 )
@@ -35,10 +33,42 @@ type Cmd struct {
 	Stdout io.Writer // Writer to write stdout into
 	Stderr io.Writer // Writer to write stdout into
 	UseCWD bool      // Whether WSL is launched in the current working directory (true) or the home directory (false)
+	Exec   bool      // Whether the program runs without any shell (true) or through the default shell of the distro (false). See CommandArgs.
+
+	// Env holds environment variables formatted as NAME=value, which are added to the
+	// environment of the distro, or replace it if ReplaceEnv is set. See Environ.
+	Env        []string
+	ReplaceEnv bool
+
+	// Dir is the Linux directory the command runs in. If empty, it is chosen by UseCWD.
+	// With Exec, it must be an absolute path.
+	Dir string
+
+	// User is the Linux user (a name or a UID) the command runs as, instead of the default user
+	// of the distro. The command is still run by the default shell of that user. The
+	// configuration of the distro is left untouched. See UnknownUserError.
+	User string
+
+	// Cancel is called when the context passed to Command is done before the command exits.
+	// Command sets it to a function that interrupts the command (see Interrupt), so that its
+	// Linux processes get the chance to clean up, and WaitDelay decides when its Windows process
+	// is killed. Wait waits for Cancel to return: with the default one, that is until the
+	// interruption has been sent, which takes up to 30 seconds. If the command does not exit
+	// successfully, Wait returns the error of the context, or the one returned by Cancel
+	// (wrapped) unless it is os.ErrProcessDone.
+	Cancel func() error
+
+	// WaitDelay is the time the command is given to exit after Cancel is called, after which its
+	// Windows process is killed. If zero, the process is killed as soon as Cancel returns. It also
+	// bounds the time spent waiting for the pipes of the command to be closed once it exits, which
+	// Linux processes left running may hold open. If those pipes are closed by force, Wait returns
+	// ErrWaitDelay. If zero, Wait does not give up on them.
+	WaitDelay time.Duration
 
 	// Immutable parameters
-	distro  *Distro // The distro that the command will be launched into.
-	command string  // The command to be launched
+	distro  *Distro  // The distro that the command will be launched into.
+	command string   // The command to be launched
+	args    []string // The program and its arguments, if the command was created by CommandArgs
 
 	// Pipes
 	closeAfterStart []io.Closer    // IO closers to be invoked after Launching the command
@@ -52,15 +82,19 @@ type Cmd struct {
 	stderrW *os.File // File that acts as a writer for WSL to write stderr into
 
 	// Book-keeping
-	handle     syscall.Handle // The windows handle to the WSL process
-	finished   bool           // Flag to fail nicely when Wait is invoked twice
-	exitStatus *uint32        // Exit status of the process. Cached because it cannot be read after the preocess is closed.
+	process  Process // The process launched by the backend
+	finished bool    // Flag to fail nicely when Wait is invoked twice
 
 	// Context management
-	ctx      context.Context // Context to kill the process before it finishes
-	waitDone chan struct{}   // This chanel prevents the context from attempting to kill the process when it is closed already
+	ctx       context.Context // Context to kill the process before it finishes
+	waitDone  chan struct{}   // This chanel prevents the context from attempting to kill the process when it is closed already
+	ctxResult chan error      // The goroutine watching the context sends the result of cancelling the command down this channel
 }
 
+// ErrWaitDelay is returned by Wait when the command exits successfully, but its pipes
+// are not closed before WaitDelay expires.
+var ErrWaitDelay = errors.New("wsl: WaitDelay expired before I/O complete")
+
 // ExitError represents a non-zero exit status from a WSL process.
 // Linux's exit errors range from 0 to 255, larger numbers correspond to Windows-side errors.
 type ExitError struct {
@@ -81,25 +115,61 @@ func (m ExitError) Is(target error) bool {
 // Command returns the Cmd struct to execute the named program with
 // the given arguments in the same string.
 //
-// It sets only the command and stdin/stdout/stderr in the returned structure.
+// It sets only the command, stdin/stdout/stderr and Cancel in the returned structure.
 //
-// The provided context is used to kill the process (by calling
-// CloseHandle) if the context becomes done before the command
-// completes on its own.
+// The provided context is used to cancel the command (see Cancel) if
+// the context becomes done before the command completes on its own.
 func (d *Distro) Command(ctx context.Context, cmd string) *Cmd {
 	if ctx == nil {
 		panic("nil Context")
 	}
-	return &Cmd{
+	c := &Cmd{
 		Stdin:   nil,
 		Stdout:  nil,
 		Stderr:  nil,
 		UseCWD:  false,
 		distro:  d,
-		handle:  0,
+		process: nil,
 		command: cmd,
 		ctx:     ctx,
 	}
+	c.Cancel = c.interrupt
+	return c
+}
+
+// CommandArgs is like Command, but the program and its arguments are passed separately.
+// They are quoted (see ShellQuote) so that the shell passes them to the program as-is,
+// without expanding variables, globs or any other shell syntax they may contain.
+//
+// WslLaunch always runs commands through the default shell of the distro, which may read
+// profile scripts and define aliases and functions. Set Exec in the returned Cmd to run the
+// program directly via `wsl.exe --exec` instead, bypassing the shell entirely. Env is then
+// applied by env(1).
+func (d *Distro) CommandArgs(ctx context.Context, name string, args ...string) *Cmd {
+	argv := append([]string{name}, args...)
+
+	c := d.Command(ctx, shellJoin(argv))
+	c.args = argv
+	return c
+}
+
+// Environ returns a copy of the environment the command would run with, as it is currently
+// configured: the variables in Env, on top of the default environment of the distro (see
+// Configuration.DefaultEnvironmentVariables) unless ReplaceEnv is set. Note that the shell of
+// the distro adds further variables, such as HOME or USER, which are not included.
+//
+// If a variable is repeated, in Env or in the default environment, the last value is the one
+// that counts.
+func (c *Cmd) Environ() []string {
+	var env []string
+	if !c.ReplaceEnv {
+		// The default environment is best-effort: if it cannot be read, there is no command to run anyway
+		if guid, err := c.distro.GUID(); err == nil {
+			env, _ = backend.Registry().StringsValue(guid, "DefaultEnvironment")
+		}
+	}
+
+	return mergeEnvironment(env, c.Env)
 }
 
 // Start starts the specified command but does not wait for it to complete.
@@ -111,14 +181,18 @@ func (c *Cmd) Start() (err error) {
 		if err == nil {
 			return
 		}
-		err = fmt.Errorf("wsl: %v", err)
-		if c.handle == 0 {
+		err = fmt.Errorf("wsl: %w", err)
+		if c.process == nil {
 			return
 		}
 		c.closeDescriptors(c.closeAfterStart)
 		c.closeDescriptors(c.closeAfterWait)
 	}()
 
+	if err := c.distro.checkPinned(); err != nil {
+		return err
+	}
+
 	r, err := c.distro.IsRegistered()
 	if err != nil {
 		return err
@@ -127,22 +201,7 @@ func (c *Cmd) Start() (err error) {
 		return errors.New("distro is not registered")
 	}
 
-	distroUTF16, err := syscall.UTF16PtrFromString(c.distro.Name)
-	if err != nil {
-		return errors.New("failed to convert distro name to UTF16")
-	}
-
-	commandUTF16, err := syscall.UTF16PtrFromString(c.command)
-	if err != nil {
-		return fmt.Errorf("failed to convert command %q to UTF16", c.command)
-	}
-
-	var useCwd wBOOL
-	if c.UseCWD {
-		useCwd = 1
-	}
-
-	if c.handle != 0 {
+	if c.process != nil {
 		return errors.New("already started")
 	}
 
@@ -156,6 +215,30 @@ func (c *Cmd) Start() (err error) {
 		}
 	}
 
+	var command string
+	var argv []string
+	if c.Exec {
+		argv, err = c.execArgs()
+	} else {
+		command, err = c.commandLine()
+	}
+	if err != nil {
+		c.closeDescriptors(c.closeAfterStart)
+		c.closeDescriptors(c.closeAfterWait)
+		return err
+	}
+
+	var user string
+	if c.User != "" {
+		// This launches a command of its own, so it must be done before setting up the pipes
+		user, err = c.distro.resolveUser(c.ctx, c.User)
+		if err != nil {
+			c.closeDescriptors(c.closeAfterStart)
+			c.closeDescriptors(c.closeAfterWait)
+			return err
+		}
+	}
+
 	type F func(*Cmd) error
 	for _, setupFd := range []F{(*Cmd).stdin, (*Cmd).stdout, (*Cmd).stderr} {
 		err := setupFd(c)
@@ -166,25 +249,25 @@ func (c *Cmd) Start() (err error) {
 		}
 	}
 
-	r1, _, _ := wslLaunch.Call(
-		uintptr(unsafe.Pointer(distroUTF16)),
-		uintptr(unsafe.Pointer(commandUTF16)),
-		uintptr(useCwd),
-		c.stdinR.Fd(),
-		c.stdoutW.Fd(),
-		c.stderrW.Fd(),
-		uintptr(unsafe.Pointer(&c.handle)))
-
-	if r1 != 0 {
-		c.closeDescriptors(c.closeAfterStart)
-		c.closeDescriptors(c.closeAfterWait)
-		return fmt.Errorf("failed syscall to WslLaunch")
+	var process Process
+	switch {
+	case c.Exec:
+		dir := c.Dir
+		if dir == "" {
+			dir = launchDir(c.UseCWD)
+		}
+		process, err = backend.LaunchExec(c.distro.Name, user, dir, argv, c.stdinR, c.stdoutW, c.stderrW)
+	case user == "":
+		process, err = backend.Launch(c.distro.Name, command, c.UseCWD, c.stdinR, c.stdoutW, c.stderrW)
+	default:
+		process, err = backend.LaunchAsUser(c.distro.Name, user, command, c.UseCWD, c.stdinR, c.stdoutW, c.stderrW)
 	}
-	if c.handle == syscall.Handle(0) {
+	if err != nil {
 		c.closeDescriptors(c.closeAfterStart)
 		c.closeDescriptors(c.closeAfterWait)
-		return fmt.Errorf("syscall to WslLaunch returned a null handle")
+		return err
 	}
+	c.process = process
 
 	c.closeDescriptors(c.closeAfterStart)
 
@@ -201,22 +284,76 @@ func (c *Cmd) Start() (err error) {
 
 	if c.ctx != nil {
 		c.waitDone = make(chan struct{})
-		go func() {
-			select {
-			case <-c.waitDone:
-				return
-			case <-c.ctx.Done():
-			}
-			err := c.kill()
-			if err != nil {
-				log.Warnf("wsl: Failed to kill process: %v", err)
-			}
-		}()
+		c.ctxResult = make(chan error, 1)
+		go c.watchCtx()
 	}
 
 	return nil
 }
 
+// watchCtx cancels the command if the context is done before the process exits, and kills
+// the process if it is still running once WaitDelay expires. The result of cancelling the
+// command is sent down c.ctxResult.
+func (c *Cmd) watchCtx() {
+	select {
+	case <-c.waitDone:
+		c.ctxResult <- nil
+		return
+	case <-c.ctx.Done():
+	}
+
+	err := c.ctx.Err()
+	if c.Cancel != nil {
+		if e := c.Cancel(); e != nil && !errors.Is(e, os.ErrProcessDone) {
+			err = fmt.Errorf("could not cancel command: %w", e)
+		}
+	}
+
+	if c.WaitDelay > 0 {
+		timer := time.NewTimer(c.WaitDelay)
+		defer timer.Stop()
+
+		select {
+		case <-c.waitDone:
+			c.ctxResult <- err
+			return
+		case <-timer.C:
+		}
+	}
+
+	if e := c.process.Kill(); e != nil && !errors.Is(e, os.ErrProcessDone) {
+		log.Warnf("wsl: Failed to kill process: %v", e)
+	}
+
+	c.ctxResult <- err
+}
+
+// interrupt is the default Cancel function. It waits for the interruption to be sent (see
+// Interrupt), so that the process is not killed before its Linux processes are signalled.
+// Failures are only logged, as the context is the reason why the command did not complete.
+func (c *Cmd) interrupt() error {
+	if err := c.process.Interrupt(); err != nil {
+		log.Warnf("wsl: Failed to interrupt process: %v", err)
+	}
+	return nil
+}
+
+// Interrupt sends SIGTERM to the Linux processes started by the command, including the ones
+// that they start in turn, without waiting for them to exit. The command must have been started.
+//
+// Processes are found by a variable added to their environment, so the ones that do not inherit
+// it are not signalled. Interactive shells (i.e. empty commands) cannot be interrupted. The signal
+// is sent via another wsl.exe, which Interrupt waits for, for up to 30 seconds.
+func (c *Cmd) Interrupt() error {
+	if c.process == nil {
+		return errors.New("wsl: Interrupt before the process started")
+	}
+	if err := c.process.Interrupt(); err != nil {
+		return fmt.Errorf("wsl: %w", err)
+	}
+	return nil
+}
+
 // Output runs the command and returns its standard output.
 // Any returned error will usually be of type *ExitError.
 // If c.Stderr was nil, Output populates ExitError.Stderr.
@@ -273,7 +410,7 @@ func (c *Cmd) StdinPipe() (io.WriteCloser, error) {
 	if c.Stdin != nil {
 		return nil, errors.New("wsl: Stdin already set")
 	}
-	if c.handle != 0 {
+	if c.process != nil {
 		return nil, errors.New("wsl: StdinPipe after process started")
 	}
 	pr, pw, err := os.Pipe()
@@ -319,7 +456,7 @@ func (c *Cmd) StdoutPipe() (io.ReadCloser, error) {
 	if c.Stdout != nil {
 		return nil, errors.New("wsl: Stdout already set")
 	}
-	if c.handle != 0 {
+	if c.process != nil {
 		return nil, errors.New("wsl: StdoutPipe after process started")
 	}
 	pr, pw, err := os.Pipe()
@@ -345,7 +482,7 @@ func (c *Cmd) StderrPipe() (io.ReadCloser, error) {
 	if c.Stderr != nil {
 		return nil, errors.New("wsl: Stderr already set")
 	}
-	if c.handle != 0 {
+	if c.process != nil {
 		return nil, errors.New("wsl: StderrPipe after process started")
 	}
 	pr, pw, err := os.Pipe()
@@ -493,7 +630,7 @@ func (c *Cmd) writerDescriptor(writer io.Writer) (f *os.File, err error) {
 //
 // Wait releases any resources associated with the Cmd.
 func (c *Cmd) Wait() error {
-	if c.handle == 0 {
+	if c.process == nil {
 		return errors.New("in Distro.Wait: not started")
 	}
 	if c.finished {
@@ -501,23 +638,19 @@ func (c *Cmd) Wait() error {
 	}
 	c.finished = true
 
-	status, waitError := c.waitProcess()
+	status, waitError := c.process.Wait()
 	// Will deal with waitError after releasing resources
 
 	// Releasing goroutines in charge of listening to context cancellation
+	var cancelError error
 	if c.waitDone != nil {
 		close(c.waitDone)
+		cancelError = <-c.ctxResult
 	}
-	c.exitStatus = &status
 
 	// Releasing goroutines in charge of pipe redirection. Collect
 	// their errors.
-	var copyError error
-	for range c.goroutine {
-		if err := <-c.errch; err != nil && copyError == nil {
-			copyError = err
-		}
-	}
+	copyError := c.awaitGoroutines()
 
 	// Releasing pipes
 	c.closeDescriptors(c.closeAfterWait)
@@ -531,6 +664,9 @@ func (c *Cmd) Wait() error {
 	if status == WindowsError {
 		return errors.New("command failed due to Windows-side error")
 	}
+	if cancelError != nil && status != 0 { // Process was most likely stopped by Cancel
+		return cancelError
+	}
 	if status == ActiveProcess { // Process was most likely interrupted by context
 		if err := c.ctx.Err(); err != nil {
 			return err
@@ -542,29 +678,30 @@ func (c *Cmd) Wait() error {
 	return copyError
 }
 
-func (c *Cmd) waitProcess() (uint32, error) {
-	event, statusError := syscall.WaitForSingleObject(c.handle, syscall.INFINITE)
-	if statusError != nil {
-		return WindowsError, fmt.Errorf("failed syscall to WaitForSingleObject: %v", statusError)
-	}
-	if event != syscall.WAIT_OBJECT_0 {
-		return WindowsError, fmt.Errorf("failed syscall to WaitForSingleObject, non-zero exit status %d", event)
+// awaitGoroutines waits for the goroutines in charge of pipe redirection, and returns the first
+// error they report. If WaitDelay expires first, the pipes are closed and ErrWaitDelay is returned.
+func (c *Cmd) awaitGoroutines() error {
+	var timeout <-chan time.Time
+	if c.WaitDelay > 0 {
+		timer := time.NewTimer(c.WaitDelay)
+		defer timer.Stop()
+		timeout = timer.C
 	}
 
-	// NOTE(brainman): It seems that sometimes process is not dead
-	// when WaitForSingleObject returns. But we do not know any
-	// other way to wait for it. Sleeping for a while seems to do
-	// the trick sometimes.
-	// See https://golang.org/issue/25965 for details.
-	time.Sleep(5 * time.Millisecond)
-
-	status, statusError := c.status()
-	ok := statusError == nil && status == 0
-
-	if err := syscall.CloseHandle(c.handle); !ok && err != nil {
-		return WindowsError, err
+	var copyError error
+	for range c.goroutine {
+		select {
+		case err := <-c.errch:
+			if err != nil && copyError == nil {
+				copyError = err
+			}
+		case <-timeout:
+			// The goroutines stop once their pipes are closed, and report to the buffered c.errch
+			c.closeDescriptors(c.closeAfterWait)
+			return ErrWaitDelay
+		}
 	}
-	return status, statusError
+	return copyError
 }
 
 // Run starts the specified WslProcess and waits for it to complete.
@@ -581,31 +718,6 @@ func (c *Cmd) Run() error {
 	return c.Wait()
 }
 
-// status querries Windows for the process' status.
-func (c *Cmd) status() (exit uint32, err error) {
-	// Retrieving from cache in case the process has been closed
-	if c.exitStatus != nil {
-		return *c.exitStatus, nil
-	}
-
-	err = syscall.GetExitCodeProcess(c.handle, &exit)
-	if err != nil {
-		return WindowsError, fmt.Errorf("failed to retrieve exit status: %v", err)
-	}
-	return exit, nil
-}
-
-// kill gets the exit status before closing the process, without checking
-// if it has finished or not.
-func (c *Cmd) kill() error {
-	status, err := c.status()
-	c.exitStatus = nil
-	if err == nil {
-		c.exitStatus = &status
-	}
-	return syscall.TerminateProcess(c.handle, ActiveProcess)
-}
-
 // prefixSuffixSaver is an io.Writer which retains the first N bytes
 // and the last N bytes written to it. The Bytes() methods reconstructs
 // it with a pretty error message.
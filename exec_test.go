@@ -1,3 +1,5 @@
+//go:build windows
+
 package wsl_test
 
 import (
@@ -8,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"testing"
 	"time"
 	"wsl"
@@ -432,6 +435,158 @@ func TestCommandOutput(t *testing.T) {
 	}
 }
 
+func TestCommandArgs(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	args := []string{"Hello, world!", "$HOME", "it's", "`whoami`", "*", ""}
+
+	for _, exec := range []bool{false, true} {
+		exec := exec
+		t.Run(fmt.Sprintf("exec=%t", exec), func(t *testing.T) {
+			cmd := d.CommandArgs(context.Background(), "printf", append([]string{`%s\n`}, args...)...)
+			cmd.Exec = exec
+
+			out, err := cmd.Output()
+			require.NoError(t, err, "unexpected failure running a command with arguments")
+			require.Equal(t, strings.Join(args, "\n")+"\n", string(out), "Arguments were not passed as-is")
+		})
+	}
+}
+
+func TestCommandEnvAndDir(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	testCases := map[string]struct {
+		env        []string
+		replaceEnv bool
+		dir        string
+
+		want string
+	}{
+		"Default":             {want: "|set|"},
+		"Env is added":        {env: []string{"GOWSL_TEST=it's $HOME"}, want: "it's $HOME|set|"},
+		"Env replaces":        {env: []string{"GOWSL_TEST=1"}, replaceEnv: true, want: "1|unset|"},
+		"Dir with whitespace": {dir: "/tmp/my dir", want: "|set|/tmp/my dir"},
+	}
+
+	require.NoError(t, d.Command(context.Background(), "mkdir -p '/tmp/my dir'").Run(), "Setup: could not create directory")
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			cmd := d.Command(context.Background(), `printf '%s|%s|%s' "$GOWSL_TEST" "$([ -n "${HOME+x}" ] && echo set || echo unset)" "$(pwd)"`)
+			cmd.Env = tc.env
+			cmd.ReplaceEnv = tc.replaceEnv
+			cmd.Dir = tc.dir
+
+			out, err := cmd.Output()
+			require.NoError(t, err, "unexpected failure running the command")
+			if tc.dir == "" {
+				// The directory depends on the user
+				require.True(t, strings.HasPrefix(string(out), tc.want), "Unexpected output: %q", out)
+				return
+			}
+			require.Equal(t, tc.want, string(out), "Unexpected output")
+		})
+	}
+}
+
+func TestCommandUser(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	require.NoError(t, d.Command(context.Background(), "useradd --uid 1042 gowsl-test").Run(), "Setup: could not create user")
+
+	testCases := map[string]struct {
+		user string
+
+		want    string
+		wantErr bool
+	}{
+		"Root by name": {user: "root", want: "root"},
+		"Root by UID":  {user: "0", want: "root"},
+		"User by name": {user: "gowsl-test", want: "gowsl-test"},
+		"User by UID":  {user: "1042", want: "gowsl-test"},
+
+		"Error on unknown user": {user: "gowsl-nobody", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			cmd := d.Command(context.Background(), "whoami")
+			cmd.User = tc.user
+
+			out, err := cmd.Output()
+			if tc.wantErr {
+				var target *wsl.UnknownUserError
+				require.ErrorAs(t, err, &target, "Expected an UnknownUserError")
+				return
+			}
+			require.NoError(t, err, "Unexpected error running a command as a user")
+			require.Equal(t, tc.want, strings.TrimSpace(string(out)), "Command ran as the wrong user")
+		})
+	}
+
+	conf, err := d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error reading the configuration")
+	require.Equal(t, uint32(0), conf.DefaultUID, "Running a command as a user should not change the default user")
+}
+
+func TestCommandUserShell(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	shell := func(user string) string {
+		cmd := d.Command(context.Background(), "readlink /proc/$$/exe")
+		cmd.User = user
+
+		out, err := cmd.Output()
+		require.NoError(t, err, "Unexpected error finding out the shell of the command")
+		return strings.TrimSpace(string(out))
+	}
+
+	// The default user of the test distro is root
+	require.Equal(t, shell(""), shell("root"), "Setting User should not change the shell that runs the command")
+}
+
+func TestCommandCancelInterrupts(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	testCases := map[string]struct {
+		backend wsl.Backend
+	}{
+		"With WslLaunch": {},
+		"With wsl.exe":   {backend: wsl.NewWSLExeBackend(nil)},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			if tc.backend != nil {
+				t.Cleanup(wsl.SetBackend(tc.backend))
+			}
+
+			const cleanUpFile = "/tmp/gowsl-interrupted"
+			defer d.Command(context.Background(), "rm -f "+cleanUpFile).Run() //nolint:errcheck // Best-effort cleanup
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// The trap only runs if the shell gets SIGTERM, and the child must get it too so that wait returns
+			err := d.Command(ctx, "trap 'echo cleaned up > "+cleanUpFile+"; exit 1' TERM; sleep 500 & wait").Run()
+			require.ErrorIs(t, err, context.DeadlineExceeded, "Expected the command to be cancelled")
+
+			require.Eventually(t, func() bool {
+				out, err := d.Command(context.Background(), "cat "+cleanUpFile).Output()
+				return err == nil && string(out) == "cleaned up\n"
+			}, 10*time.Second, 500*time.Millisecond, "The command should have had the chance to clean up")
+
+			// The brackets keep pgrep from finding the shell that runs it
+			out, err := d.Command(context.Background(), "pgrep -f 'sleep [5]00'").Output()
+			require.Error(t, err, "No process should survive the cancelled command. Found: %s", out)
+		})
+	}
+}
+
 func TestCommandCombinedOutput(t *testing.T) {
 	realDistro := newTestDistro(t, jammyRootFs)
 	fakeDistro := wsl.Distro{Name: uniqueDistroName(t)}
@@ -0,0 +1,110 @@
+package wsl
+
+// This file contains utilities to export the filesystem of a distro.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Format is the format of a distro's filesystem when it is exported or imported.
+type Format int
+
+const (
+	// FormatTar is a tarball of the root filesystem of the distro.
+	FormatTar Format = iota
+
+	// FormatVHD is the virtual hard disk (.vhdx) of the distro. Only WSL2 distros support it.
+	FormatVHD
+)
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case FormatTar:
+		return "tar"
+	case FormatVHD:
+		return "vhd"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+type exportOptions struct {
+	format Format
+}
+
+// WithExportFormat is an optional parameter for Export and ExportTo that sets the format of
+// the exported filesystem. By default, it is exported as a tarball.
+func WithExportFormat(format Format) func(*exportOptions) {
+	return func(o *exportOptions) {
+		o.format = format
+	}
+}
+
+// Export writes the filesystem of the distro into a file at path. The file is created, or
+// truncated if it already exists. If the export fails, the file is removed.
+//
+// It is analogous to
+//
+//	`wsl.exe --export <distro> <path> [--vhd]`
+//
+// The export stops if the context is cancelled.
+func (d Distro) Export(ctx context.Context, path string, opts ...func(*exportOptions)) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error exporting %q: %v", d.Name, err)
+	}
+
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(path)
+			return
+		}
+		if err = f.Close(); err != nil {
+			os.Remove(path)
+			err = fmt.Errorf("error exporting %q: %v", d.Name, err)
+		}
+	}()
+
+	return d.ExportTo(ctx, f, opts...)
+}
+
+// ExportTo writes the filesystem of the distro into w.
+//
+// It is analogous to
+//
+//	`wsl.exe --export <distro> - [--vhd]`
+//
+// The export stops if the context is cancelled.
+func (d Distro) ExportTo(ctx context.Context, w io.Writer, opts ...func(*exportOptions)) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error exporting %q: %w", d.Name, err)
+		}
+	}()
+
+	options := exportOptions{
+		format: FormatTar,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if err := d.checkPinned(); err != nil {
+		return err
+	}
+
+	r, err := d.IsRegistered()
+	if err != nil {
+		return err
+	}
+	if !r {
+		return errors.New("not registered")
+	}
+
+	return backend.Export(ctx, d.Name, w, options.format)
+}
@@ -0,0 +1,44 @@
+package wsl_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendExport(t *testing.T) {
+	useFakeBackend(t)
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	dst := filepath.Join(t.TempDir(), "exported.tar")
+
+	err := d.Export(context.Background(), dst)
+	require.Error(t, err, "Unexpected success exporting an unregistered distro")
+	require.NoFileExists(t, dst, "The destination file should be removed after a failed export")
+
+	require.NoError(t, d.Register(fakeRootFs(t)), "Setup: could not register fake distro")
+
+	require.NoError(t, d.Export(context.Background(), dst), "Unexpected error exporting to a file")
+	f, err := os.Open(dst)
+	require.NoError(t, err, "Could not open the exported file")
+	defer f.Close()
+	hdr, err := tar.NewReader(f).Next()
+	require.NoError(t, err, "The exported file should be a tarball")
+	require.Equal(t, "etc/fake-distro", hdr.Name, "Unexpected contents of the exported tarball")
+
+	var buff bytes.Buffer
+	require.NoError(t, d.ExportTo(context.Background(), &buff, wsl.WithExportFormat(wsl.FormatVHD)), "Unexpected error exporting to a writer")
+	require.True(t, bytes.HasPrefix(buff.Bytes(), []byte("vhdxfile")), "The exported data should be a VHDX")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = d.ExportTo(ctx, io.Discard)
+	require.ErrorIs(t, err, context.Canceled, "Expected the export to be cancelled")
+}
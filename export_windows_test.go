@@ -0,0 +1,41 @@
+package wsl_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	testCases := map[string]struct {
+		distroName string
+		format     wsl.Format
+		wantErr    bool
+	}{
+		"success with tar format": {distroName: d.Name, format: wsl.FormatTar},
+		"success with vhd format": {distroName: d.Name, format: wsl.FormatVHD},
+		"distro not registered":   {distroName: "IAmNotRegistered", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			d := wsl.Distro{Name: tc.distroName}
+			dst := filepath.Join(t.TempDir(), "exported")
+
+			err := d.Export(context.Background(), dst, wsl.WithExportFormat(tc.format))
+			if tc.wantErr {
+				require.Error(t, err, "unexpected success in Export")
+				require.NoFileExists(t, dst, "Export should remove the destination file on failure")
+				return
+			}
+			require.NoError(t, err, "unexpected failure in Export")
+			require.FileExists(t, dst, "Export should create the destination file")
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package wsl
+
+// This file contains utilities to read and write the global configuration file %UserProfile%\.wslconfig.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is an amount of memory or disk space, as written in .wslconfig (e.g. 4GB).
+type ByteSize uint64
+
+// Units of ByteSize. Like in .wslconfig, they are powers of 1024.
+const (
+	Byte ByteSize = 1
+	KB            = 1024 * Byte
+	MB            = 1024 * KB
+	GB            = 1024 * MB
+	TB            = 1024 * GB
+)
+
+// ParseByteSize parses a size as written in .wslconfig: an integer optionally followed by one of
+// the units B, K, KB, M, MB, G, GB, T, TB (case-insensitive). Integers without units are bytes.
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+
+	digits := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if digits < 0 {
+		digits = len(s)
+	}
+	if digits == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	n, err := strconv.ParseUint(s[:digits], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %v", s, err)
+	}
+
+	var unit ByteSize
+	switch strings.ToUpper(strings.TrimSpace(s[digits:])) {
+	case "", "B":
+		unit = Byte
+	case "K", "KB":
+		unit = KB
+	case "M", "MB":
+		unit = MB
+	case "G", "GB":
+		unit = GB
+	case "T", "TB":
+		unit = TB
+	default:
+		return 0, fmt.Errorf("invalid size %q: unknown unit", s)
+	}
+
+	hi, lo := bits.Mul64(n, uint64(unit))
+	if hi != 0 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return ByteSize(lo), nil
+}
+
+// String formats the size with the largest unit that represents it exactly.
+func (s ByteSize) String() string {
+	for _, u := range []struct {
+		size ByteSize
+		name string
+	}{{TB, "TB"}, {GB, "GB"}, {MB, "MB"}, {KB, "KB"}} {
+		if s != 0 && s%u.size == 0 {
+			return fmt.Sprintf("%d%s", s/u.size, u.name)
+		}
+	}
+	return strconv.FormatUint(uint64(s), 10)
+}
+
+// NetworkingMode is the networking architecture of the WSL2 virtual machine.
+type NetworkingMode string
+
+// Some of the networking modes supported by WSL.
+const (
+	NetworkingModeNAT      NetworkingMode = "NAT"
+	NetworkingModeMirrored NetworkingMode = "mirrored"
+	NetworkingModeNone     NetworkingMode = "none"
+)
+
+// GlobalConfig is the contents of .wslconfig, which configures the virtual machine where all WSL2
+// distros run. Only the most common settings are typed. Any other key, as well as comments and
+// formatting, is preserved when the file is written back. Nil and zero fields are removed from
+// the file, so that WSL uses its defaults.
+type GlobalConfig struct {
+	Memory              ByteSize       // [wsl2] memory: memory assigned to the VM
+	Processors          uint           // [wsl2] processors: number of processors assigned to the VM
+	Swap                *ByteSize      // [wsl2] swap: size of the swap disk (zero disables swap)
+	SwapFile            string         // [wsl2] swapFile: Windows path of the swap disk
+	Kernel              string         // [wsl2] kernel: Windows path of a custom kernel
+	LocalhostForwarding *bool          // [wsl2] localhostForwarding: whether ports bound in the VM are reachable from Windows' localhost
+	NetworkingMode      NetworkingMode // [wsl2] networkingMode: networking architecture of the VM
+
+	// [experimental] section. Keys are stored as they are, without validation.
+	Experimental map[string]string
+
+	doc *iniFile // Document the configuration was parsed from
+}
+
+// fields returns the typed fields of the configuration, in the order they are
+// added to a new file.
+func (c *GlobalConfig) fields() []iniField {
+	return []iniField{
+		sizeField("wsl2", "memory", &c.Memory),
+		uintField("wsl2", "processors", &c.Processors),
+		optionalSizeField("wsl2", "swap", &c.Swap),
+		pathField("wsl2", "swapFile", &c.SwapFile),
+		pathField("wsl2", "kernel", &c.Kernel),
+		boolField("wsl2", "localhostForwarding", &c.LocalhostForwarding),
+		stringField("wsl2", "networkingMode", (*string)(&c.NetworkingMode)),
+	}
+}
+
+// GlobalConfigPath returns the path of .wslconfig, in the user's home directory.
+func GlobalConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find .wslconfig: %w", err)
+	}
+	return filepath.Join(home, ".wslconfig"), nil
+}
+
+// LoadGlobalConfig reads and parses a .wslconfig file (see GlobalConfigPath). If the file does
+// not exist, an empty configuration is returned.
+func LoadGlobalConfig(path string) (GlobalConfig, error) {
+	out, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return GlobalConfig{}, nil
+	} else if err != nil {
+		return GlobalConfig{}, fmt.Errorf("could not read .wslconfig: %w", err)
+	}
+
+	return ParseGlobalConfig(out)
+}
+
+// ParseGlobalConfig parses the contents of a .wslconfig file.
+func ParseGlobalConfig(data []byte) (conf GlobalConfig, err error) {
+	doc, err := parseINI(data)
+	if err != nil {
+		return conf, fmt.Errorf("could not parse .wslconfig: %v", err)
+	}
+
+	if err := doc.loadFields(conf.fields()); err != nil {
+		return conf, fmt.Errorf("could not parse .wslconfig: %v", err)
+	}
+
+	for _, key := range doc.keys("experimental") {
+		if conf.Experimental == nil {
+			conf.Experimental = make(map[string]string)
+		}
+		conf.Experimental[key], _ = doc.get("experimental", key)
+	}
+
+	conf.doc = doc
+	return conf, nil
+}
+
+// Bytes serializes the configuration in the .wslconfig format. Lines of the parsed file
+// whose setting did not change are written as they were. Values containing line breaks,
+// or quotes as well as surrounding spaces or comment characters, cannot be written.
+func (c GlobalConfig) Bytes() ([]byte, error) {
+	doc := newINIFile()
+	var prev GlobalConfig
+	if c.doc != nil {
+		doc = c.doc.clone()
+		_ = doc.loadFields(prev.fields()) // It was already loaded successfully when parsing
+	}
+
+	doc.storeFields(c.fields(), prev.fields())
+
+	for _, key := range doc.keys("experimental") {
+		if _, ok := c.Experimental[key]; !ok {
+			doc.remove("experimental", key)
+		}
+	}
+
+	keys := make([]string, 0, len(c.Experimental))
+	for key := range c.Experimental {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		doc.set("experimental", key, c.Experimental[key])
+	}
+
+	out, err := doc.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize .wslconfig: %v", err)
+	}
+	return out, nil
+}
+
+// Save writes the configuration into a .wslconfig file (see GlobalConfigPath). The file is
+// replaced atomically, so it is never left half-written, and it keeps the permissions of the
+// file it replaces.
+func (c GlobalConfig) Save(path string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("could not write .wslconfig: %w", err)
+		}
+	}()
+
+	data, err := c.Bytes()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".wslconfig-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // No-op after a successful rename
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// CreateTemp makes the file only accessible to its owner
+	if info, err := os.Stat(path); err == nil {
+		if err := os.Chmod(f.Name(), info.Mode().Perm()); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// UpdateGlobalConfig loads the .wslconfig file at path (see GlobalConfigPath), modifies it with f
+// and saves it. Changes to .wslconfig only take effect once the WSL virtual machine restarts, so
+// WSL is shut down if the configuration changed while any WSL2 distro was running. WSL1 distros do
+// not run in the virtual machine, so they are not taken into account. It returns whether WSL was
+// shut down.
+func UpdateGlobalConfig(path string, f func(*GlobalConfig)) (restarted bool, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("could not update .wslconfig: %w", err)
+		}
+	}()
+
+	conf, err := LoadGlobalConfig(path)
+	if err != nil {
+		return false, err
+	}
+
+	before, err := conf.Bytes()
+	if err != nil {
+		return false, err
+	}
+
+	f(&conf)
+
+	after, err := conf.Bytes()
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(before, after) {
+		return false, nil
+	}
+
+	// Checking the states before saving, so that nothing is written if WSL is not available
+	states, err := States()
+	if err != nil {
+		return false, err
+	}
+
+	if err := conf.Save(path); err != nil {
+		return false, err
+	}
+
+	for name, s := range states {
+		if s != Running {
+			continue
+		}
+		c, err := (&Distro{Name: name}).GetConfiguration()
+		if err != nil {
+			return false, err
+		}
+		if c.WSLVersion != 2 {
+			continue
+		}
+		if err := Shutdown(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// sizeField binds a key to a size. Zero sizes are not set.
+func sizeField(section, key string, p *ByteSize) iniField {
+	return iniField{
+		section: section,
+		key:     key,
+		parse: func(v string) (err error) {
+			*p, err = ParseByteSize(v)
+			return err
+		},
+		format: func() (string, bool) {
+			return p.String(), *p != 0
+		},
+	}
+}
+
+// optionalSizeField binds a key to an optional size.
+func optionalSizeField(section, key string, p **ByteSize) iniField {
+	return iniField{
+		section: section,
+		key:     key,
+		parse: func(v string) error {
+			s, err := ParseByteSize(v)
+			if err != nil {
+				return err
+			}
+			*p = &s
+			return nil
+		},
+		format: func() (string, bool) {
+			if *p == nil {
+				return "", false
+			}
+			return (*p).String(), true
+		},
+	}
+}
+
+// uintField binds a key to an unsigned integer. Zero is not set.
+func uintField(section, key string, p *uint) iniField {
+	return iniField{
+		section: section,
+		key:     key,
+		parse: func(v string) error {
+			n, err := strconv.ParseUint(v, 10, 0)
+			if err != nil {
+				return fmt.Errorf("%q is not a positive integer", v)
+			}
+			*p = uint(n)
+			return nil
+		},
+		format: func() (string, bool) {
+			return strconv.FormatUint(uint64(*p), 10), *p != 0
+		},
+	}
+}
+
+// pathField binds a key to a Windows path. In .wslconfig, backslashes in paths are escaped.
+// Empty paths are not set.
+func pathField(section, key string, p *string) iniField {
+	return iniField{
+		section: section,
+		key:     key,
+		parse: func(v string) error {
+			*p = strings.ReplaceAll(v, `\\`, `\`)
+			return nil
+		},
+		format: func() (string, bool) {
+			return strings.ReplaceAll(*p, `\`, `\\`), *p != ""
+		},
+	}
+}
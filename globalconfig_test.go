@@ -0,0 +1,323 @@
+package wsl_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+const sampleGlobalConfig = `# Settings apply across all Linux distros running on WSL 2
+[wsl2]
+memory=4096MB
+processors = 2
+swap = 0
+kernel = C:\\temp\\myCustomKernel
+localhostForwarding = True
+nestedVirtualization = false ; Unknown key
+networkingMode = mirrored
+
+[experimental]
+autoMemoryReclaim = gradual
+sparseVhd = true
+`
+
+func TestParseByteSize(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		text string
+
+		want     wsl.ByteSize
+		wantText string
+		wantErr  bool
+	}{
+		"Bytes without unit":       {text: "1000", want: 1000, wantText: "1000"},
+		"Bytes with unit":          {text: "1000B", want: 1000, wantText: "1000"},
+		"Zero":                     {text: "0", want: 0, wantText: "0"},
+		"Kilobytes":                {text: "512KB", want: 512 * wsl.KB, wantText: "512KB"},
+		"Megabytes":                {text: "512MB", want: 512 * wsl.MB, wantText: "512MB"},
+		"Gigabytes":                {text: "8GB", want: 8 * wsl.GB, wantText: "8GB"},
+		"Terabytes":                {text: "1TB", want: wsl.TB, wantText: "1TB"},
+		"Short unit":               {text: "8G", want: 8 * wsl.GB, wantText: "8GB"},
+		"Lowercase unit":           {text: "8gb", want: 8 * wsl.GB, wantText: "8GB"},
+		"Spaces":                   {text: " 8 GB ", want: 8 * wsl.GB, wantText: "8GB"},
+		"Formatted in larger unit": {text: "4096MB", want: 4 * wsl.GB, wantText: "4GB"},
+		"Not a multiple of a unit": {text: "1025KB", want: 1025 * wsl.KB, wantText: "1025KB"},
+
+		// Error cases
+		"Error on empty size":     {text: "", wantErr: true},
+		"Error on missing number": {text: "GB", wantErr: true},
+		"Error on negative size":  {text: "-1GB", wantErr: true},
+		"Error on decimal size":   {text: "1.5GB", wantErr: true},
+		"Error on unknown unit":   {text: "8GiB", wantErr: true},
+		"Error on overflow":       {text: "20000000TB", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wsl.ParseByteSize(tc.text)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success parsing size")
+				return
+			}
+			require.NoError(t, err, "Unexpected error parsing size")
+			require.Equal(t, tc.want, got, "Unexpected size")
+			require.Equal(t, tc.wantText, got.String(), "Unexpected formatting of the size")
+		})
+	}
+}
+
+func TestParseGlobalConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		data string
+
+		want    wsl.GlobalConfig
+		wantErr bool
+	}{
+		"Empty file":                 {data: "", want: wsl.GlobalConfig{}},
+		"Only an unknown section":    {data: "[wsl1]\nmemory = 1GB\n", want: wsl.GlobalConfig{}},
+		"Unescaped path":             {data: "[wsl2]\nkernel = C:\\kernel\n", want: wsl.GlobalConfig{Kernel: `C:\kernel`}},
+		"Quoted path":                {data: "[wsl2]\nswapFile = \"C:\\\\my swap.vhdx\"\n", want: wsl.GlobalConfig{SwapFile: `C:\my swap.vhdx`}},
+		"Empty experimental section": {data: "[experimental]\n", want: wsl.GlobalConfig{}},
+		"Full file": {data: sampleGlobalConfig, want: wsl.GlobalConfig{
+			Memory:              4 * wsl.GB,
+			Processors:          2,
+			Swap:                ptr(wsl.ByteSize(0)),
+			Kernel:              `C:\temp\myCustomKernel`,
+			LocalhostForwarding: ptr(true),
+			NetworkingMode:      wsl.NetworkingModeMirrored,
+			Experimental:        map[string]string{"autoMemoryReclaim": "gradual", "sparseVhd": "true"},
+		}},
+
+		// Error cases
+		"Error on invalid INI":           {data: "[wsl2\n", wantErr: true},
+		"Error on invalid size":          {data: "[wsl2]\nmemory = lots\n", wantErr: true},
+		"Error on negative processors":   {data: "[wsl2]\nprocessors = -1\n", wantErr: true},
+		"Error on non-boolean typed key": {data: "[wsl2]\nlocalhostForwarding = sometimes\n", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wsl.ParseGlobalConfig([]byte(tc.data))
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success parsing .wslconfig")
+				return
+			}
+			require.NoError(t, err, "Unexpected error parsing .wslconfig")
+
+			require.Equal(t, tc.want.Memory, got.Memory, "Unexpected value for Memory")
+			require.Equal(t, tc.want.Processors, got.Processors, "Unexpected value for Processors")
+			require.Equal(t, tc.want.Swap, got.Swap, "Unexpected value for Swap")
+			require.Equal(t, tc.want.SwapFile, got.SwapFile, "Unexpected value for SwapFile")
+			require.Equal(t, tc.want.Kernel, got.Kernel, "Unexpected value for Kernel")
+			require.Equal(t, tc.want.LocalhostForwarding, got.LocalhostForwarding, "Unexpected value for LocalhostForwarding")
+			require.Equal(t, tc.want.NetworkingMode, got.NetworkingMode, "Unexpected value for NetworkingMode")
+			require.Equal(t, tc.want.Experimental, got.Experimental, "Unexpected value for Experimental")
+		})
+	}
+}
+
+func TestGlobalConfigBytes(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		data   string
+		update func(*wsl.GlobalConfig)
+
+		want    string
+		wantErr bool
+	}{
+		"Unchanged file is written as is": {data: sampleGlobalConfig, want: sampleGlobalConfig},
+		"Equivalent size is kept as is":   {data: "[wsl2]\nmemory = 4096MB\n", update: func(c *wsl.GlobalConfig) { c.Memory = 4 * wsl.GB }, want: "[wsl2]\nmemory = 4096MB\n"},
+
+		"Change a size": {
+			data:   "[wsl2]\nmemory = 4096MB\n",
+			update: func(c *wsl.GlobalConfig) { c.Memory = 8 * wsl.GB },
+			want:   "[wsl2]\nmemory = 8GB\n",
+		},
+		"Disable swap": {
+			data:   "[wsl2]\nmemory = 4GB\n",
+			update: func(c *wsl.GlobalConfig) { c.Swap = ptr(wsl.ByteSize(0)) },
+			want:   "[wsl2]\nmemory = 4GB\nswap = 0\n",
+		},
+		"Reset to the default": {
+			data:   "[wsl2]\nmemory = 4GB\nprocessors = 4\nswap = 0\n",
+			update: func(c *wsl.GlobalConfig) { c.Processors, c.Swap = 0, nil },
+			want:   "[wsl2]\nmemory = 4GB\n",
+		},
+		"Escape paths": {
+			data:   "",
+			update: func(c *wsl.GlobalConfig) { c.Kernel = `C:\Users\me\kernel` },
+			want:   "[wsl2]\nkernel = C:\\\\Users\\\\me\\\\kernel\n",
+		},
+		"Change experimental keys": {
+			data: "[wsl2]\nmemory = 4GB\n\n[experimental]\n# Comment\nsparseVhd = true\nautoMemoryReclaim = gradual\n",
+			update: func(c *wsl.GlobalConfig) {
+				delete(c.Experimental, "sparseVhd")
+				c.Experimental["autoMemoryReclaim"] = "dropcache"
+				c.Experimental["dnsTunneling"] = "true"
+			},
+			want: "[wsl2]\nmemory = 4GB\n\n[experimental]\n# Comment\nautoMemoryReclaim = dropcache\ndnsTunneling = true\n",
+		},
+		"Write a new file": {
+			update: func(c *wsl.GlobalConfig) {
+				*c = wsl.GlobalConfig{
+					Memory:              8 * wsl.GB,
+					Processors:          4,
+					LocalhostForwarding: ptr(false),
+					NetworkingMode:      wsl.NetworkingModeNAT,
+					Experimental:        map[string]string{"sparseVhd": "true"},
+				}
+			},
+			want: "[wsl2]\nmemory = 8GB\nprocessors = 4\nlocalhostForwarding = false\nnetworkingMode = NAT\n\n[experimental]\nsparseVhd = true\n",
+		},
+
+		// Error cases
+		"Error on an experimental value starting with quotes": {update: func(c *wsl.GlobalConfig) { c.Experimental = map[string]string{"key": `"x"`} }, wantErr: true},
+		"Error on a path with quotes and a comment":           {update: func(c *wsl.GlobalConfig) { c.Kernel = `C:\"kernels" #1` }, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			conf, err := wsl.ParseGlobalConfig([]byte(tc.data))
+			require.NoError(t, err, "Setup: could not parse .wslconfig")
+
+			if tc.update != nil {
+				tc.update(&conf)
+			}
+
+			out, err := conf.Bytes()
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success serializing .wslconfig")
+				return
+			}
+			require.NoError(t, err, "Unexpected error serializing .wslconfig")
+			got := string(out)
+			require.Equal(t, tc.want, got, "Unexpected serialized .wslconfig")
+
+			reparsed, err := wsl.ParseGlobalConfig([]byte(got))
+			require.NoError(t, err, "Serialized .wslconfig should be parseable")
+			out, err = reparsed.Bytes()
+			require.NoError(t, err, "Unexpected error serializing the reparsed .wslconfig")
+			require.Equal(t, got, string(out), "Serialized .wslconfig should round-trip")
+		})
+	}
+}
+
+func TestGlobalConfigSave(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".wslconfig")
+
+	conf, err := wsl.LoadGlobalConfig(path)
+	require.NoError(t, err, "Unexpected error loading a missing .wslconfig")
+	out, err := conf.Bytes()
+	require.NoError(t, err, "Unexpected error serializing an empty configuration")
+	require.Empty(t, out, "A missing .wslconfig should result in an empty configuration")
+
+	require.NoError(t, os.WriteFile(path, []byte(sampleGlobalConfig), 0600), "Setup: could not write .wslconfig")
+
+	conf, err = wsl.LoadGlobalConfig(path)
+	require.NoError(t, err, "Unexpected error loading .wslconfig")
+	require.Equal(t, uint(2), conf.Processors, "Unexpected number of processors")
+
+	conf.Processors = 8
+	require.NoError(t, conf.Save(path), "Unexpected error saving .wslconfig")
+
+	conf, err = wsl.LoadGlobalConfig(path)
+	require.NoError(t, err, "Unexpected error loading .wslconfig after saving it")
+	require.Equal(t, uint(8), conf.Processors, "Processors were not saved")
+	require.Equal(t, 4*wsl.GB, conf.Memory, "Other settings should not change")
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err, "Setup: could not read the directory of .wslconfig")
+	require.Len(t, entries, 1, "Saving should not leave temporary files behind")
+
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Chmod(path, 0644), "Setup: could not change the permissions of .wslconfig")
+		require.NoError(t, conf.Save(path), "Unexpected error saving .wslconfig")
+		info, err := os.Stat(path)
+		require.NoError(t, err, "Setup: could not stat .wslconfig")
+		require.Equal(t, fs.FileMode(0644), info.Mode().Perm(), "Saving should keep the permissions of .wslconfig")
+	}
+
+	err = conf.Save(filepath.Join(path, "not-a-directory", ".wslconfig"))
+	require.Error(t, err, "Unexpected success saving into a directory that does not exist")
+}
+
+func TestFakeBackendUpdateGlobalConfig(t *testing.T) {
+	fake := useFakeBackend(t)
+	scriptSleepInfinity(fake)
+
+	d := registerFakeDistro(t, "FakeDistro")
+	wsl1 := registerFakeDistro(t, "FakeDistroWSL1")
+	require.NoError(t, wsl1.SetVersion(context.Background(), 1), "Setup: could not convert the distro to WSL1")
+
+	path := filepath.Join(t.TempDir(), ".wslconfig")
+	setMemory := func(size wsl.ByteSize) func(*wsl.GlobalConfig) {
+		return func(c *wsl.GlobalConfig) { c.Memory = size }
+	}
+
+	restarted, err := wsl.UpdateGlobalConfig(path, setMemory(4*wsl.GB))
+	require.NoError(t, err, "Unexpected error updating .wslconfig")
+	require.False(t, restarted, "WSL should not restart when no distro is running")
+
+	out, err := os.ReadFile(path)
+	require.NoError(t, err, ".wslconfig should have been written")
+	require.Equal(t, "[wsl2]\nmemory = 4GB\n", string(out), "Unexpected contents of .wslconfig")
+
+	cmdWSL1 := wsl1.Command(context.Background(), "sleep infinity")
+	require.NoError(t, cmdWSL1.Start(), "Setup: could not start a process in the WSL1 fake distro")
+
+	restarted, err = wsl.UpdateGlobalConfig(path, setMemory(2*wsl.GB))
+	require.NoError(t, err, "Unexpected error updating .wslconfig")
+	require.False(t, restarted, "WSL should not restart when only WSL1 distros are running")
+
+	state, err := wsl1.State()
+	require.NoError(t, err, "Unexpected error getting the state of the WSL1 distro")
+	require.Equal(t, wsl.Running, state, "The WSL1 distro should still be running")
+
+	cmd := d.Command(context.Background(), "sleep infinity")
+	require.NoError(t, cmd.Start(), "Setup: could not start a process in the fake distro")
+
+	restarted, err = wsl.UpdateGlobalConfig(path, setMemory(2*wsl.GB))
+	require.NoError(t, err, "Unexpected error updating .wslconfig without changes")
+	require.False(t, restarted, "WSL should not restart when the configuration does not change")
+
+	state, err = d.State()
+	require.NoError(t, err, "Unexpected error getting the state of the distro")
+	require.Equal(t, wsl.Running, state, "The distro should still be running")
+
+	restarted, err = wsl.UpdateGlobalConfig(path, setMemory(8*wsl.GB))
+	require.NoError(t, err, "Unexpected error updating .wslconfig")
+	require.True(t, restarted, "WSL should restart when the configuration changes while a distro is running")
+	err = cmd.Wait()
+	require.ErrorIs(t, err, wsl.ExitError{}, "The process should have been killed by the shutdown")
+	require.Equal(t, wsl.ActiveProcess, err.(*wsl.ExitError).Code) //nolint: forcetypeassert, errorlint
+	require.Error(t, cmdWSL1.Wait(), "The process in the WSL1 distro should have been killed by the shutdown")
+
+	state, err = d.State()
+	require.NoError(t, err, "Unexpected error getting the state of the distro")
+	require.Equal(t, wsl.Stopped, state, "The distro should have been shut down")
+
+	require.NoError(t, os.WriteFile(path, []byte("[wsl2]\nmemory = lots\n"), 0600), "Setup: could not write .wslconfig")
+	_, err = wsl.UpdateGlobalConfig(path, setMemory(8*wsl.GB))
+	require.Error(t, err, "Unexpected success updating an invalid .wslconfig")
+}
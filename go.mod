@@ -2,12 +2,14 @@ module wsl
 
 go 1.18
 
-require golang.org/x/sys v0.1.0
+require (
+	github.com/0xrawsec/golang-utils v1.3.2
+	github.com/stretchr/testify v1.8.1
+	golang.org/x/sys v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/0xrawsec/golang-utils v1.3.2 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.8.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,95 @@
+package wsl
+
+// This file contains utilities to identify distros by the GUID of their registry key.
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+)
+
+// ErrDistroReplaced is returned by operations on a pinned Distro (see Distro.Pin) when the
+// distro it was pinned to is no longer the one registered with its name.
+var ErrDistroReplaced = errors.New("distro was replaced")
+
+// guidRegex matches the name of the registry key of a distro, e.g. {ee8aef7a-846f-4561-a028-79504ce65cd3}.
+var guidRegex = regexp.MustCompile(`^\{[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\}$`)
+
+// DistroByGUID returns the distro whose registry key has the specified GUID. The returned
+// Distro is pinned to that GUID (see Distro.Pin).
+func DistroByGUID(guid string) (Distro, error) {
+	if !guidRegex.MatchString(guid) {
+		return Distro{}, fmt.Errorf("invalid GUID %q", guid)
+	}
+
+	name, err := readRegistryDistributionName(backend.Registry(), guid)
+	if err != nil {
+		return Distro{}, fmt.Errorf("could not find distro with GUID %s: %w", guid, err)
+	}
+
+	return Distro{Name: name, guid: guid}, nil
+}
+
+// GUID returns the GUID of the registry key of the distro. For pinned distros, it fails
+// with ErrDistroReplaced if the distro was replaced since it was pinned.
+func (d Distro) GUID() (string, error) {
+	if d.guid != "" {
+		if err := d.checkPinned(); err != nil {
+			return "", err
+		}
+		return d.guid, nil
+	}
+
+	p, err := d.Properties()
+	if err != nil {
+		return "", err
+	}
+	return p.GUID, nil
+}
+
+// Pin binds the distro to the GUID of the distro currently registered with its name. From
+// then on, operations on it fail with ErrDistroReplaced if that distro is unregistered,
+// renamed, or replaced with another one with the same name.
+//
+// Registering or importing a pinned distro pins it to the newly registered GUID.
+func (d *Distro) Pin() error {
+	p, err := Distro{Name: d.Name}.Properties()
+	if err != nil {
+		return fmt.Errorf("could not pin distro: %w", err)
+	}
+
+	d.guid = p.GUID
+	return nil
+}
+
+// repin pins a pinned distro to the distro currently registered with its name. It is
+// used after registering it. Distros that are not pinned are left as they are.
+func (d *Distro) repin() error {
+	if d.guid == "" {
+		return nil
+	}
+	return d.Pin()
+}
+
+// checkPinned verifies that the distro registered with the GUID the distro is pinned to is
+// still the same one. Distros that are not pinned always pass this check.
+func (d Distro) checkPinned() error {
+	if d.guid == "" {
+		return nil
+	}
+
+	name, err := readRegistryDistributionName(backend.Registry(), d.guid)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %q is no longer registered with GUID %s", ErrDistroReplaced, d.Name, d.guid)
+	}
+	if err != nil {
+		return err
+	}
+
+	if name != d.Name {
+		return fmt.Errorf("%w: distro with GUID %s was renamed from %q to %q", ErrDistroReplaced, d.guid, d.Name, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package wsl_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendGUID(t *testing.T) {
+	useFakeBackend(t)
+	rootfs := fakeRootFs(t)
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	_, err := d.GUID()
+	require.Error(t, err, "Unexpected success getting the GUID of an unregistered distro")
+
+	require.NoError(t, d.Register(rootfs), "Setup: could not register fake distro")
+
+	guid, err := d.GUID()
+	require.NoError(t, err, "Unexpected error getting the GUID")
+
+	byGUID, err := wsl.DistroByGUID(guid)
+	require.NoError(t, err, "Unexpected error finding a distro by its GUID")
+	require.Equal(t, d.Name, byGUID.Name, "Unexpected distro found by GUID")
+
+	g, err := byGUID.GUID()
+	require.NoError(t, err, "Unexpected error getting the GUID of a pinned distro")
+	require.Equal(t, guid, g, "Unexpected GUID of a pinned distro")
+
+	_, err = wsl.DistroByGUID("{00000000-0000-0000-0000-000000000000}")
+	require.Error(t, err, "Unexpected success finding a distro with an unregistered GUID")
+	_, err = wsl.DistroByGUID("AppxInstallerCache")
+	require.Error(t, err, "Unexpected success finding a distro with an invalid GUID")
+}
+
+func TestFakeBackendPinnedDistro(t *testing.T) {
+	fake := useFakeBackend(t)
+	rootfs := fakeRootFs(t)
+
+	fake.Script("exit 0", wsl.FakeResult("", "", 0))
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	require.Error(t, d.Pin(), "Unexpected success pinning an unregistered distro")
+
+	require.NoError(t, d.Register(rootfs), "Setup: could not register fake distro")
+	require.NoError(t, d.Pin(), "Unexpected error pinning a distro")
+
+	reg, err := d.IsRegistered()
+	require.NoError(t, err, "Unexpected error checking if a pinned distro is registered")
+	require.True(t, reg, "Pinned distro should be registered")
+	require.NoError(t, d.Command(context.Background(), "exit 0").Run(), "Unexpected error running a command in a pinned distro")
+
+	// Replace the distro with another one with the same name
+	unpinned := wsl.Distro{Name: d.Name}
+	require.NoError(t, unpinned.Unregister(), "Setup: could not unregister fake distro")
+	require.NoError(t, unpinned.Register(rootfs), "Setup: could not re-register fake distro")
+
+	reg, err = d.IsRegistered()
+	require.NoError(t, err, "Unexpected error checking if a replaced distro is registered")
+	require.False(t, reg, "Replaced distro should not be registered")
+
+	testCases := map[string]func() error{
+		"GUID":                  func() error { _, err := d.GUID(); return err },
+		"Properties":            func() error { _, err := d.Properties(); return err },
+		"GetConfiguration":      func() error { _, err := d.GetConfiguration(); return err },
+		"DefaultUID":            func() error { return d.DefaultUID(1000) },
+		"SetDefaultEnvironment": func() error { return d.SetDefaultEnvironment(nil) },
+		"Rename":                func() error { return d.Rename("NewName") },
+		"Move":                  func() error { return d.Move(context.Background(), filepath.Join(t.TempDir(), "new")) },
+		"Command":               func() error { return d.Command(context.Background(), "exit 0").Run() },
+		"Shell":                 func() error { return d.Shell(wsl.WithCommand("exit 0")) },
+		"SetAsDefault":          func() error { return d.SetAsDefault() },
+		"Terminate":             func() error { return d.Terminate() },
+		"Unregister":            func() error { return d.Unregister() },
+	}
+
+	for name, f := range testCases {
+		f := f
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, f(), wsl.ErrDistroReplaced, "Expected ErrDistroReplaced on a replaced distro")
+		})
+	}
+
+	// Registering a pinned distro pins it to the new GUID
+	pinned := wsl.Distro{Name: "OtherFakeDistro"}
+	require.NoError(t, pinned.Register(rootfs), "Setup: could not register fake distro")
+	require.NoError(t, pinned.Pin(), "Setup: could not pin fake distro")
+	require.NoError(t, pinned.Unregister(), "Setup: could not unregister fake distro")
+	require.NoError(t, pinned.Register(rootfs), "Unexpected error registering a pinned distro again")
+	require.NoError(t, pinned.Terminate(), "Pinned distro should follow the newly registered distro")
+}
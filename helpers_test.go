@@ -0,0 +1,46 @@
+package wsl_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+// useFakeBackend replaces the package's backend with a new FakeBackend for the duration of the test.
+func useFakeBackend(t *testing.T) *wsl.FakeBackend {
+	t.Helper()
+
+	fake := wsl.NewFakeBackend()
+	t.Cleanup(wsl.SetBackend(fake))
+	return fake
+}
+
+// fakeRootFs creates an empty file to be used as a rootfs with the FakeBackend.
+func fakeRootFs(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	err := os.WriteFile(path, []byte{}, 0600)
+	require.NoError(t, err, "Setup: could not create fake rootfs")
+	return path
+}
+
+// registerFakeDistro registers a distro with the specified name in the FakeBackend.
+func registerFakeDistro(t *testing.T, name string) wsl.Distro {
+	t.Helper()
+
+	d := wsl.Distro{Name: name}
+	require.NoError(t, d.Register(fakeRootFs(t)), "Setup: could not register fake distro")
+	return d
+}
+
+// scriptSleepInfinity makes `sleep infinity` run in the FakeBackend until it is killed.
+func scriptSleepInfinity(fake *wsl.FakeBackend) {
+	fake.Script("sleep infinity", func(p *wsl.FakeProcess) uint32 {
+		<-p.Killed
+		return 0
+	})
+}
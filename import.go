@@ -0,0 +1,105 @@
+package wsl
+
+// This file contains utilities to create distros with wsl.exe --import.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+type importOptions struct {
+	format  Format
+	version uint8
+}
+
+// WithImportFormat is an optional parameter for Import that sets the format of the file the
+// distro is imported from. By default, it is expected to be a tarball.
+func WithImportFormat(format Format) func(*importOptions) {
+	return func(o *importOptions) {
+		o.format = format
+	}
+}
+
+// WithVersion is an optional parameter for Import that sets the WSL version (1 or 2) of the
+// imported distro. By default, the default version of the system is used.
+func WithVersion(version uint8) func(*importOptions) {
+	return func(o *importOptions) {
+		o.version = version
+	}
+}
+
+// Import creates a new distro from a tarball (or a VHD, see WithImportFormat). Unlike Register,
+// the filesystem of the distro is stored in installDir, which is created if necessary.
+//
+// It is analogous to
+//
+//	`wsl.exe --import <distro> <installDir> <source> [--version <version>] [--vhd]`
+func (d *Distro) Import(ctx context.Context, source string, installDir string, opts ...func(*importOptions)) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error importing %q: %w", d.Name, err)
+		}
+	}()
+
+	options := importOptions{
+		format: FormatTar,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.format == FormatVHD && options.version == 1 {
+		return errors.New("VHD format is only supported by WSL2")
+	}
+
+	source, err = fixPath(source)
+	if err != nil {
+		return err
+	}
+
+	installDir, err = filepath.Abs(filepath.FromSlash(installDir))
+	if err != nil {
+		return err
+	}
+
+	if err := d.checkNameAvailable(); err != nil {
+		return err
+	}
+
+	if err := backend.Import(ctx, d.Name, installDir, source, options.format, options.version); err != nil {
+		return err
+	}
+
+	return d.repin()
+}
+
+// ImportInPlace creates a new distro that uses an existing VHD as its filesystem, without
+// copying it.
+//
+// It is analogous to
+//
+//	`wsl.exe --import-in-place <distro> <vhdx>`
+func (d *Distro) ImportInPlace(ctx context.Context, vhdx string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error importing %q in place: %w", d.Name, err)
+		}
+	}()
+
+	vhdx, err = fixPath(vhdx)
+	if err != nil {
+		return err
+	}
+
+	if err := d.checkNameAvailable(); err != nil {
+		return err
+	}
+
+	if err := backend.ImportInPlace(ctx, d.Name, vhdx); err != nil {
+		return err
+	}
+
+	return d.repin()
+}
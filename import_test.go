@@ -0,0 +1,55 @@
+package wsl_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendImport(t *testing.T) {
+	useFakeBackend(t)
+	ctx := context.Background()
+
+	golden := registerFakeDistro(t, "FakeGolden")
+
+	tarball := filepath.Join(t.TempDir(), "golden.tar")
+	require.NoError(t, golden.Export(ctx, tarball), "Setup: could not export fake distro")
+	vhdx := filepath.Join(t.TempDir(), "ext4.vhdx")
+	require.NoError(t, golden.Export(ctx, vhdx, wsl.WithExportFormat(wsl.FormatVHD)), "Setup: could not export fake distro")
+
+	installDir := filepath.Join(t.TempDir(), "install")
+
+	d := wsl.Distro{Name: "FakeImported"}
+	require.NoError(t, d.Import(ctx, tarball, installDir, wsl.WithVersion(1)), "Unexpected error importing a tarball")
+
+	p, err := d.Properties()
+	require.NoError(t, err, "Unexpected error getting the properties of the imported distro")
+	require.Equal(t, installDir, p.BasePath, "Imported distro should be stored in the install directory")
+	require.Zero(t, p.Flags&0x8, "Imported distro should be WSL1")
+
+	err = d.Import(ctx, tarball, installDir)
+	require.Error(t, err, "Unexpected success importing a distro twice")
+
+	err = (&wsl.Distro{Name: "FakeFromVHD"}).Import(ctx, vhdx, installDir, wsl.WithImportFormat(wsl.FormatVHD))
+	require.NoError(t, err, "Unexpected error importing a VHD")
+
+	err = (&wsl.Distro{Name: "FakeNotVHD"}).Import(ctx, tarball, installDir, wsl.WithImportFormat(wsl.FormatVHD))
+	var wslExeErr *wsl.WSLExeError
+	require.ErrorAs(t, err, &wslExeErr, "Expected a WSLExeError importing a tarball as a VHD")
+	require.NotEmpty(t, wslExeErr.Code, "Expected an error code importing a tarball as a VHD")
+
+	err = (&wsl.Distro{Name: "FakeWSL1VHD"}).Import(ctx, vhdx, installDir, wsl.WithImportFormat(wsl.FormatVHD), wsl.WithVersion(1))
+	require.Error(t, err, "Unexpected success importing a VHD as WSL1")
+
+	err = (&wsl.Distro{Name: "FakeMissing"}).Import(ctx, filepath.Join(t.TempDir(), "missing.tar"), installDir)
+	require.Error(t, err, "Unexpected success importing a file that does not exist")
+
+	inPlace := wsl.Distro{Name: "FakeInPlace"}
+	require.NoError(t, inPlace.ImportInPlace(ctx, vhdx), "Unexpected error importing a VHD in place")
+	p, err = inPlace.Properties()
+	require.NoError(t, err, "Unexpected error getting the properties of the imported distro")
+	require.Equal(t, filepath.Dir(vhdx), p.BasePath, "Distro imported in place should be stored next to the VHD")
+}
@@ -0,0 +1,45 @@
+package wsl_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImport(t *testing.T) {
+	testCases := map[string]struct {
+		rootfs  string
+		version uint8
+		wantErr bool
+	}{
+		"success with default version": {rootfs: jammyRootFs},
+		"success with version 1":       {rootfs: jammyRootFs, version: 1},
+		"success with version 2":       {rootfs: jammyRootFs, version: 2},
+		"rootfs does not exist":        {rootfs: "./does/not/exist.tar.gz", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			d := wsl.Distro{Name: uniqueDistroName(t)}
+			defer func() {
+				_ = cleanUpWslInstance(d)
+			}()
+
+			installDir := filepath.Join(t.TempDir(), "install")
+			err := d.Import(context.Background(), tc.rootfs, installDir, wsl.WithVersion(tc.version))
+			if tc.wantErr {
+				require.Error(t, err, "unexpected success in Import")
+				return
+			}
+			require.NoError(t, err, "unexpected failure in Import")
+
+			p, err := d.Properties()
+			require.NoError(t, err, "unexpected failure in Properties")
+			require.Equal(t, installDir, p.BasePath, "Imported distro should be stored in the install directory")
+		})
+	}
+}
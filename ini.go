@@ -0,0 +1,373 @@
+package wsl
+
+// This file contains a minimal INI document model, used for WSL's configuration files.
+// It keeps comments, unknown keys and the original formatting of untouched lines, so
+// that editing a file only changes the lines that need changing.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// iniFile is a parsed INI document.
+type iniFile struct {
+	sections []*iniSection // The first section is the (unnamed) preamble before any header
+}
+
+// iniSection is a section of an INI document, along with all the lines up to the next header.
+type iniSection struct {
+	name   string    // Name of the section, empty for the preamble
+	header string    // Original header line, empty if the section was not parsed
+	lines  []iniLine // Entries, comments and blank lines, in order
+}
+
+// iniLine is a line of an INI section.
+type iniLine struct {
+	raw   string // Original text of the line, empty if it must be formatted from key and value
+	key   string // Key of the entry, empty for comments and blank lines
+	value string // Value of the entry, without quotes or trailing comments
+}
+
+// newINIFile creates an empty document.
+func newINIFile() *iniFile {
+	return &iniFile{sections: []*iniSection{{}}}
+}
+
+// parseINI parses an INI document. Keys outside of any section are allowed. Lines starting
+// with '#' or ';' are comments, as is anything after a '#' or ';' preceded by whitespace in
+// an unquoted value. Values may be surrounded by double quotes.
+func parseINI(data []byte) (*iniFile, error) {
+	f := newINIFile()
+	section := f.sections[0]
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		raw := strings.TrimSuffix(sc.Text(), "\r")
+		line := strings.TrimSpace(raw)
+
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff") // Byte order mark
+		}
+
+		switch {
+		case line == "", line[0] == '#', line[0] == ';':
+			section.lines = append(section.lines, iniLine{raw: raw})
+		case line[0] == '[':
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated section header %q", n, line)
+			}
+			name := strings.TrimSpace(line[1:end])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty section name", n)
+			}
+			section = &iniSection{name: name, header: raw}
+			f.sections = append(f.sections, section)
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected key = value, got %q", n, line)
+			}
+			key = strings.TrimSpace(key)
+			if key == "" {
+				return nil, fmt.Errorf("line %d: empty key", n)
+			}
+			value, err := parseINIValue(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			section.lines = append(section.lines, iniLine{raw: raw, key: key, value: value})
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// parseINIValue removes the quotes and trailing comments from a value.
+func parseINIValue(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		end := strings.IndexByte(s[1:], '"')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", s)
+		}
+		return s[1 : end+1], nil
+	}
+
+	if i := iniCommentStart(s); i >= 0 {
+		return strings.TrimSpace(s[:i]), nil
+	}
+	return s, nil
+}
+
+// iniCommentStart returns the index where the trailing comment of an unquoted value starts,
+// or -1 if there is none. Comments must be preceded by whitespace.
+func iniCommentStart(s string) int {
+	for i := 1; i < len(s); i++ {
+		if (s[i] == '#' || s[i] == ';') && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// formatINIValue quotes a value if it would not be parsed back as is. There is no way to escape
+// quotes, so values that contain them and would need quoting cannot be written.
+func formatINIValue(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return "", fmt.Errorf("value %q contains a line break", s)
+	}
+
+	bare := s == strings.TrimSpace(s) && !strings.HasPrefix(s, `"`)
+	if bare && !strings.ContainsAny(s, "#;") {
+		return s, nil
+	}
+
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`, nil
+	}
+
+	// Comment characters are only quoted for readability, so they can be left as they are
+	if bare && iniCommentStart(s) < 0 {
+		return s, nil
+	}
+
+	return "", fmt.Errorf("value %q contains quotes, but it cannot be written without quoting it", s)
+}
+
+// Bytes serializes the document. Lines that were not modified are written as they were read.
+func (f *iniFile) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	for _, s := range f.sections {
+		if s.name != "" {
+			if s.header != "" {
+				buf.WriteString(s.header)
+			} else {
+				fmt.Fprintf(&buf, "[%s]", s.name)
+			}
+			buf.WriteByte('\n')
+		}
+
+		for _, l := range s.lines {
+			if l.raw != "" || l.key == "" {
+				buf.WriteString(l.raw)
+			} else {
+				v, err := formatINIValue(l.value)
+				if err != nil {
+					return nil, fmt.Errorf("[%s] %s: %v", s.name, l.key, err)
+				}
+				fmt.Fprintf(&buf, "%s = %s", l.key, v)
+			}
+			buf.WriteByte('\n')
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// clone returns a deep copy of the document.
+func (f *iniFile) clone() *iniFile {
+	c := &iniFile{sections: make([]*iniSection, 0, len(f.sections))}
+	for _, s := range f.sections {
+		sc := *s
+		sc.lines = append([]iniLine{}, s.lines...)
+		c.sections = append(c.sections, &sc)
+	}
+	return c
+}
+
+// get returns the value of a key. Section and key names are case-insensitive.
+// If the key is repeated, the last occurrence is the one that counts.
+func (f *iniFile) get(section, key string) (value string, ok bool) {
+	l := f.find(section, key)
+	if l == nil {
+		return "", false
+	}
+	return l.value, true
+}
+
+// keys returns the names of the keys in a section, in order of appearance and without repetitions.
+func (f *iniFile) keys(section string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, s := range f.sections {
+		if !strings.EqualFold(s.name, section) {
+			continue
+		}
+		for _, l := range s.lines {
+			if l.key == "" || seen[strings.ToLower(l.key)] {
+				continue
+			}
+			seen[strings.ToLower(l.key)] = true
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// set overrides the value of a key, or adds it to the end of its section if it is not
+// present. The section is created if needed. Lines whose value does not change are
+// left untouched.
+func (f *iniFile) set(section, key, value string) {
+	if l := f.find(section, key); l != nil {
+		if l.value != value {
+			*l = iniLine{key: l.key, value: value}
+		}
+		return
+	}
+
+	s := f.section(section)
+
+	// Adding the key after the last entry, so that blank lines and comments
+	// separating the section from the next one stay where they are.
+	i := len(s.lines)
+	for i > 0 && s.lines[i-1].key == "" {
+		i--
+	}
+	if i == 0 && s.name == "" {
+		i = len(s.lines) // The preamble may start with comments about the whole file
+	}
+
+	s.lines = append(s.lines[:i], append([]iniLine{{key: key, value: value}}, s.lines[i:]...)...)
+}
+
+// remove deletes all occurrences of a key. Sections left empty are kept.
+func (f *iniFile) remove(section, key string) {
+	for _, s := range f.sections {
+		if !strings.EqualFold(s.name, section) {
+			continue
+		}
+		lines := s.lines[:0]
+		for _, l := range s.lines {
+			if l.key != "" && strings.EqualFold(l.key, key) {
+				continue
+			}
+			lines = append(lines, l)
+		}
+		s.lines = lines
+	}
+}
+
+// find returns the last occurrence of a key, or nil if it is not present.
+func (f *iniFile) find(section, key string) *iniLine {
+	var found *iniLine
+	for _, s := range f.sections {
+		if !strings.EqualFold(s.name, section) {
+			continue
+		}
+		for i := range s.lines {
+			if s.lines[i].key != "" && strings.EqualFold(s.lines[i].key, key) {
+				found = &s.lines[i]
+			}
+		}
+	}
+	return found
+}
+
+// section returns the last section with the specified name, creating it if needed.
+func (f *iniFile) section(name string) *iniSection {
+	for i := len(f.sections) - 1; i >= 0; i-- {
+		if strings.EqualFold(f.sections[i].name, name) {
+			return f.sections[i]
+		}
+	}
+
+	// Separating the new section from the previous one with a blank line
+	if last := f.sections[len(f.sections)-1]; last.name != "" || len(last.lines) > 0 {
+		if n := len(last.lines); n == 0 || strings.TrimSpace(last.lines[n-1].raw) != "" || last.lines[n-1].key != "" {
+			last.lines = append(last.lines, iniLine{})
+		}
+	}
+
+	s := &iniSection{name: name}
+	f.sections = append(f.sections, s)
+	return s
+}
+
+// iniField binds a key of an INI document to a typed value.
+type iniField struct {
+	section string
+	key     string
+	parse   func(v string) error  // Sets the typed value from its text
+	format  func() (string, bool) // Returns the text of the typed value, or false if it is not set
+}
+
+// loadFields sets the typed values of the fields present in the document.
+func (f *iniFile) loadFields(fields []iniField) error {
+	for _, field := range fields {
+		v, ok := f.get(field.section, field.key)
+		if !ok {
+			continue
+		}
+		if err := field.parse(v); err != nil {
+			return fmt.Errorf("[%s] %s: %v", field.section, field.key, err)
+		}
+	}
+	return nil
+}
+
+// storeFields writes the typed values of the fields into the document, and removes the keys of
+// the fields that are not set. prev must be the same fields, loaded from the document with
+// loadFields: lines whose typed value did not change are kept as they were, so that the
+// original spelling (e.g. "True" or "4096MB") is preserved.
+func (f *iniFile) storeFields(fields []iniField, prev []iniField) {
+	for i, field := range fields {
+		v, ok := field.format()
+		if !ok {
+			f.remove(field.section, field.key)
+			continue
+		}
+
+		if p, ok := prev[i].format(); ok && p == v {
+			if _, ok := f.get(field.section, field.key); ok {
+				continue
+			}
+		}
+
+		f.set(field.section, field.key, v)
+	}
+}
+
+// stringField binds a key to a string. Empty strings are not set.
+func stringField(section, key string, p *string) iniField {
+	return iniField{
+		section: section,
+		key:     key,
+		parse: func(v string) error {
+			*p = v
+			return nil
+		},
+		format: func() (string, bool) {
+			return *p, *p != ""
+		},
+	}
+}
+
+// boolField binds a key to an optional boolean.
+func boolField(section, key string, p **bool) iniField {
+	return iniField{
+		section: section,
+		key:     key,
+		parse: func(v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%q is not a boolean", v)
+			}
+			*p = &b
+			return nil
+		},
+		format: func() (string, bool) {
+			if *p == nil {
+				return "", false
+			}
+			return strconv.FormatBool(**p), true
+		},
+	}
+}
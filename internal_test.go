@@ -72,3 +72,53 @@ func TestPackFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestDiffConfiguration(t *testing.T) {
+	t.Parallel()
+
+	current := Configuration{
+		Version:                     2,
+		DefaultUID:                  0,
+		InteropEnabled:              true,
+		PathAppended:                true,
+		DriveMountingEnabled:        true,
+		WSLVersion:                  2,
+		DefaultEnvironmentVariables: map[string]string{"LANG": "en_US.UTF-8"},
+	}
+
+	testCases := map[string]struct {
+		update func(*Configuration)
+
+		want    []string
+		wantErr bool
+	}{
+		"no changes":                              {update: func(c *Configuration) {}, want: nil},
+		"one change":                              {update: func(c *Configuration) { c.DefaultUID = 1000 }, want: []string{"DefaultUID"}},
+		"all mutable fields":                      {update: func(c *Configuration) { *c = Configuration{DefaultUID: 1000} }, want: []string{"DefaultUID", "InteropEnabled", "PathAppended", "DriveMountingEnabled"}},
+		"same immutable fields are allowed":       {update: func(c *Configuration) { c.PathAppended = false }, want: []string{"PathAppended"}},
+		"empty environment variables are ignored": {update: func(c *Configuration) { c.DefaultEnvironmentVariables = nil }, want: nil},
+
+		// Error cases
+		"error changing Version":                     {update: func(c *Configuration) { c.Version = 1 }, wantErr: true},
+		"error changing WSLVersion":                  {update: func(c *Configuration) { c.WSLVersion = 1 }, wantErr: true},
+		"error changing DefaultEnvironmentVariables": {update: func(c *Configuration) { c.DefaultEnvironmentVariables = map[string]string{} }, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := current
+			tc.update(&config)
+
+			got, err := diffConfiguration(current, config)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success diffing configurations")
+				return
+			}
+			require.NoError(t, err, "Unexpected error diffing configurations")
+			require.Equal(t, tc.want, got, "Unexpected changed fields")
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package wsl
+
+// This file contains utilities to interrupt the Linux processes started by a command.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// processMarkerVar is the environment variable that identifies the Linux processes started
+// by a command. The processes started by those inherit it too, so that the whole tree can
+// be found and interrupted even after the Windows process is gone.
+const processMarkerVar = "GOWSL_PROCESS"
+
+// interruptTimeout bounds the time spent interrupting the processes of a command.
+const interruptTimeout = 30 * time.Second
+
+// newProcessMarker returns a random value for processMarkerVar. It only contains
+// hexadecimal digits, so that it needs no quoting.
+func newProcessMarker() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate process marker: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// markCommand prefixes the command so that the shell that runs it exports a new marker to
+// the processes it starts, and returns both. An empty command starts an interactive shell,
+// so it is returned as is, with an empty marker.
+func markCommand(command string) (marked string, marker string, err error) {
+	if command == "" {
+		return "", "", nil
+	}
+
+	marker, err = newProcessMarker()
+	if err != nil {
+		return "", "", err
+	}
+
+	return fmt.Sprintf("export %s=%s; %s", processMarkerVar, marker, command), marker, nil
+}
+
+// markEnvironment adds the marker to a Windows environment, and lists it in WSLENV so that
+// wsl.exe passes it on to the Linux process.
+func markEnvironment(env []string, marker string) []string {
+	wslEnv := processMarkerVar
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "WSLENV=") && kv != "WSLENV=" {
+			wslEnv = strings.TrimPrefix(kv, "WSLENV=") + ":" + processMarkerVar
+		}
+	}
+
+	return append(env, processMarkerVar+"="+marker, "WSLENV="+wslEnv)
+}
+
+// interruptScript returns a shell script that sends SIGTERM to every process whose environment
+// or command line contains the marker. The latter finds the shell that exports the marker, whose
+// own environment does not change. Processes that cannot be inspected or signalled are skipped.
+func interruptScript(marker string) string {
+	// Bracketing the first digit keeps the pattern from matching the script and grep themselves
+	pattern := fmt.Sprintf("%s=[%c]%s", processMarkerVar, marker[0], marker[1:])
+
+	return fmt.Sprintf(`for p in /proc/[0-9]*; do `+
+		`cat "$p/environ" "$p/cmdline" 2>/dev/null | tr '\0' '\n' | grep -q '%s' && kill -TERM "${p#/proc/}" 2>/dev/null; `+
+		`done; true`, pattern)
+}
+
+// interruptProcesses sends SIGTERM to the processes marked with the marker, running the script
+// via wsl.exe as the specified user (or the default one if it is empty). Only their owner and
+// root are allowed to signal them.
+func interruptProcesses(distroName, user, marker string) error {
+	if marker == "" {
+		return errors.New("interactive shells cannot be interrupted")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), interruptTimeout)
+	defer cancel()
+
+	// The script is run by /bin/sh, as the default shell of the user might not understand it
+	argv := []string{"/bin/sh", "-c", interruptScript(marker)}
+	if err := wslExe(ctx, nil, execArgs(distroName, user, launchDir(false), argv)...); err != nil {
+		return fmt.Errorf("could not interrupt process: %w", err)
+	}
+	return nil
+}
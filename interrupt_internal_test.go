@@ -0,0 +1,190 @@
+package wsl
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkCommand(t *testing.T) {
+	t.Parallel()
+
+	marked, marker, err := markCommand("echo 'Hello'")
+	require.NoError(t, err, "Unexpected error marking a command")
+	require.Regexp(t, "^[0-9a-f]{32}$", marker, "Marker should be made of hexadecimal digits")
+	require.Equal(t, "export GOWSL_PROCESS="+marker+"; echo 'Hello'", marked, "Unexpected marked command")
+
+	_, other, err := markCommand("echo 'Hello'")
+	require.NoError(t, err, "Unexpected error marking a command")
+	require.NotEqual(t, marker, other, "Every command should get a different marker")
+
+	marked, marker, err = markCommand("")
+	require.NoError(t, err, "Unexpected error marking an interactive shell")
+	require.Empty(t, marked, "Interactive shells should not be marked")
+	require.Empty(t, marker, "Interactive shells should have no marker")
+}
+
+func TestMarkEnvironment(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		env []string
+
+		wantWSLEnv string
+	}{
+		"Without WSLENV":        {env: []string{"PATH=C:\\Windows"}, wantWSLEnv: "WSLENV=GOWSL_PROCESS"},
+		"With an empty WSLENV":  {env: []string{"WSLENV="}, wantWSLEnv: "WSLENV=GOWSL_PROCESS"},
+		"With a previous value": {env: []string{"WSLENV=USERPROFILE/p"}, wantWSLEnv: "WSLENV=USERPROFILE/p:GOWSL_PROCESS"},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := markEnvironment(tc.env, "1234")
+
+			// Later values take precedence
+			want := append(tc.env, "GOWSL_PROCESS=1234", tc.wantWSLEnv)
+			require.Equal(t, want, got, "Unexpected environment")
+		})
+	}
+}
+
+func TestInterruptScript(t *testing.T) {
+	t.Parallel()
+
+	if _, err := os.Stat("/proc/self/environ"); err != nil {
+		t.Skip("This test requires procfs")
+	}
+
+	marked, marker, err := markCommand("sleep 60; echo 'not interrupted'")
+	require.NoError(t, err, "Setup: could not mark command")
+
+	// The marked shell runs sleep as a child, which inherits the marker too
+	//nolint:gosec // G204: The command is made up by the test
+	target := exec.Command("/bin/sh", "-c", marked)
+	require.NoError(t, target.Start(), "Setup: could not start marked command")
+	defer target.Process.Kill() //nolint:errcheck // Only in case the test fails
+
+	control := exec.Command("sleep", "60")
+	require.NoError(t, control.Start(), "Setup: could not start unmarked command")
+	defer control.Process.Kill() //nolint:errcheck // Only in case the test fails
+
+	// Giving the shell some time to export the marker and start sleep
+	time.Sleep(500 * time.Millisecond)
+
+	//nolint:gosec // G204: The script is made up by the test
+	out, err := exec.Command("/bin/sh", "-c", interruptScript(marker)).CombinedOutput()
+	require.NoError(t, err, "Unexpected error running the interrupt script: %s", out)
+	require.Empty(t, out, "The interrupt script should not write anything")
+
+	err = target.Wait()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr, "Marked command should have been interrupted")
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	require.True(t, ok, "Unexpected type of exit status")
+	require.Equal(t, syscall.SIGTERM, status.Signal(), "Marked command should have been stopped by SIGTERM")
+
+	require.NoError(t, control.Process.Signal(syscall.Signal(0)), "Unmarked command should not have been interrupted")
+}
+
+func TestInterruptProcesses(t *testing.T) {
+	testCases := map[string]struct {
+		user    string
+		marker  string
+		standIn standIn
+
+		wantArgs []string
+		wantErr  bool
+	}{
+		"Default user":  {marker: "1234", wantArgs: []string{"--distribution", "SomeDistro", "--cd", "~", "--exec", "/bin/sh", "-c", interruptScript("1234")}},
+		"Specific user": {user: "root", marker: "1234", wantArgs: []string{"--distribution", "SomeDistro", "--user", "root", "--cd", "~", "--exec", "/bin/sh", "-c", interruptScript("1234")}},
+
+		// Error cases
+		"Error on interactive shells": {wantErr: true},
+		"Error when wsl.exe fails":    {marker: "1234", standIn: standIn{stdout: "There is no distribution with the supplied name.\n", exitCode: 1}, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			recordedArgs := useStandInWslExe(t, tc.standIn)
+
+			err := interruptProcesses("SomeDistro", tc.user, tc.marker)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success interrupting processes")
+				if tc.standIn.exitCode != 0 {
+					var target *WSLExeError
+					require.ErrorAs(t, err, &target, "Expected a WSLExeError")
+				}
+				return
+			}
+			require.NoError(t, err, "Unexpected error interrupting processes")
+			require.Equal(t, tc.wantArgs, recordedArgs(), "Unexpected arguments passed to wsl.exe")
+		})
+	}
+}
+
+func TestInterruptWithReplaceEnv(t *testing.T) {
+	t.Parallel()
+
+	if _, err := os.Stat("/proc/self/environ"); err != nil {
+		t.Skip("This test requires procfs")
+	}
+
+	env := []string{"PATH=" + os.Getenv("PATH")}
+
+	testCases := map[string]struct {
+		cmd Cmd
+	}{
+		"Through the shell": {cmd: Cmd{command: "sleep 60; echo 'not interrupted'", Env: env, ReplaceEnv: true}},
+		"With Exec":         {cmd: Cmd{args: []string{"sleep", "60"}, Env: env, ReplaceEnv: true, Exec: true}},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			marker, err := newProcessMarker()
+			require.NoError(t, err, "Setup: could not generate marker")
+
+			// The marker is in the environment of the process, as wsl.exe or markCommand leave it
+			var target *exec.Cmd
+			if tc.cmd.Exec {
+				argv, err := tc.cmd.execArgs()
+				require.NoError(t, err, "Setup: could not build the arguments")
+				//nolint:gosec // G204: The command is made up by the test
+				target = exec.Command(argv[0], argv[1:]...)
+			} else {
+				line, err := tc.cmd.commandLine()
+				require.NoError(t, err, "Setup: could not build the command line")
+				//nolint:gosec // G204: The command is made up by the test
+				target = exec.Command("/bin/sh", "-c", line)
+			}
+			target.Env = append(os.Environ(), processMarkerVar+"="+marker)
+
+			require.NoError(t, target.Start(), "Setup: could not start marked command")
+			defer target.Process.Kill() //nolint:errcheck // Only in case the test fails
+
+			// Giving the command some time to replace its environment
+			time.Sleep(500 * time.Millisecond)
+
+			//nolint:gosec // G204: The script is made up by the test
+			out, err := exec.Command("/bin/sh", "-c", interruptScript(marker)).CombinedOutput()
+			require.NoError(t, err, "Unexpected error running the interrupt script: %s", out)
+
+			err = target.Wait()
+			var exitErr *exec.ExitError
+			require.ErrorAs(t, err, &exitErr, "Command with a replaced environment should have been interrupted")
+			status, ok := exitErr.Sys().(syscall.WaitStatus)
+			require.True(t, ok, "Unexpected type of exit status")
+			require.Equal(t, syscall.SIGTERM, status.Signal(), "Command should have been stopped by SIGTERM")
+		})
+	}
+}
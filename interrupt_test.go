@@ -0,0 +1,112 @@
+package wsl_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendCommandCancel(t *testing.T) {
+	errCancel := errors.New("could not cancel")
+	interrupt := func(c *wsl.Cmd) func() error { return c.Interrupt }
+
+	testCases := map[string]struct {
+		cancel     func(*wsl.Cmd) func() error // Replaces the default Cancel if not nil. It may return a nil Cancel.
+		waitDelay  time.Duration
+		ignoreTerm bool // Whether the script ignores the interruption
+
+		wantEvents []string
+		wantErr    error
+	}{
+		"Default cancel interrupts before killing": {ignoreTerm: true, wantEvents: []string{"interrupted", "killed"}, wantErr: context.Canceled},
+		"Default cancel lets the process exit":     {waitDelay: time.Minute, wantEvents: []string{"interrupted"}, wantErr: context.Canceled},
+		"Default cancel kills after WaitDelay":     {waitDelay: 100 * time.Millisecond, ignoreTerm: true, wantEvents: []string{"interrupted", "killed"}, wantErr: context.Canceled},
+		"Interrupt lets the process exit":          {cancel: interrupt, waitDelay: time.Minute, wantEvents: []string{"interrupted"}, wantErr: context.Canceled},
+		"WaitDelay kills an unresponsive process":  {cancel: interrupt, waitDelay: 100 * time.Millisecond, ignoreTerm: true, wantEvents: []string{"interrupted", "killed"}, wantErr: context.Canceled},
+		"Without WaitDelay the process is killed":  {cancel: interrupt, ignoreTerm: true, wantEvents: []string{"interrupted", "killed"}, wantErr: context.Canceled},
+		"Without Cancel the process is killed":     {cancel: func(*wsl.Cmd) func() error { return nil }, wantEvents: []string{"killed"}, wantErr: context.Canceled},
+		"Error from Cancel is returned": {
+			cancel:     func(c *wsl.Cmd) func() error { return func() error { _ = c.Interrupt(); return errCancel } },
+			waitDelay:  time.Minute,
+			wantEvents: []string{"interrupted"},
+			wantErr:    errCancel,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			fake := useFakeBackend(t)
+
+			d := registerFakeDistro(t, "FakeDistro")
+
+			// The script reports what happened to it, in order
+			events := make(chan string, 2)
+			fake.Script("sleep infinity", func(p *wsl.FakeProcess) uint32 {
+				defer close(events)
+				select {
+				case <-p.Interrupted:
+				case <-p.Killed:
+					select {
+					case <-p.Interrupted:
+					default:
+						events <- "killed"
+						return 0
+					}
+				}
+				events <- "interrupted"
+				if !tc.ignoreTerm {
+					return 143
+				}
+				<-p.Killed
+				events <- "killed"
+				return 0
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			cmd := d.Command(ctx, "sleep infinity")
+			if tc.cancel != nil {
+				cmd.Cancel = tc.cancel(cmd)
+			}
+			cmd.WaitDelay = tc.waitDelay
+
+			require.NoError(t, cmd.Start(), "Unexpected error starting the command")
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			err := cmd.Wait()
+			require.ErrorIs(t, err, tc.wantErr, "Unexpected error after cancelling the command")
+
+			// The script may outlive the process if it is killed
+			var got []string
+			for e := range events {
+				got = append(got, e)
+			}
+			require.Equal(t, tc.wantEvents, got, "Unexpected events in the process")
+		})
+	}
+
+	t.Run("WaitDelay bounds the wait for the pipes", func(t *testing.T) {
+		fake := useFakeBackend(t)
+
+		d := registerFakeDistro(t, "FakeDistro")
+		fake.Script("true", wsl.FakeResult("", "", 0))
+
+		// A reader that never returns keeps the goroutine copying stdin busy
+		r, w := io.Pipe()
+		defer w.Close()
+
+		cmd := d.Command(context.Background(), "true")
+		cmd.Stdin = r
+		cmd.WaitDelay = 100 * time.Millisecond
+
+		err := cmd.Run()
+		require.ErrorIs(t, err, wsl.ErrWaitDelay, "Expected Wait to give up on the pipes")
+	})
+}
@@ -1,3 +1,5 @@
+//go:build windows
+
 package wsl_test
 
 // This file contains testing functionality
@@ -52,22 +54,12 @@ func uniqueID() string {
 	return fmt.Sprintf("%d", rand.Intn(100_000_000)) //nolint:gosec
 }
 
-// sanitizeDistroName sanitizes the name of the disto as much as possible.
-func sanitizeDistroName(candidateName string) string {
-	r := strings.NewReplacer(
-		`/`, `--`,
-		` `, `_`,
-		`\`, `--`,
-	)
-	return r.Replace(candidateName)
-}
-
 // Generates a unique distro name. It does not create the distro.
 func uniqueDistroName(t *testing.T) string {
 	t.Helper()
 	const maxAttempts = 10
 	for i := 0; i < maxAttempts; i++ {
-		d := wsl.Distro{Name: sanitizeDistroName(fmt.Sprintf("%s_%s_%s", namePrefix, t.Name(), uniqueID()))}
+		d := wsl.Distro{Name: wsl.SanitizeName(fmt.Sprintf("%s_%s_%s", namePrefix, t.Name(), uniqueID()))}
 		// Ensuring no name collision
 		exists, err := d.IsRegistered()
 		if err != nil {
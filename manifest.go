@@ -0,0 +1,326 @@
+package wsl
+
+// This file contains utilities to converge the distros of a machine to a desired state.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest is the desired state of the distros of a machine. Distros that are not listed
+// are left as they are.
+type Manifest struct {
+	Distros []DistroManifest `json:"distros" yaml:"distros"`
+}
+
+// DistroManifest is the desired state of a distro. Settings left empty are not changed.
+type DistroManifest struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Absent means that the distro must be unregistered. No other setting may be used along with it.
+	Absent bool `json:"absent,omitempty" yaml:"absent,omitempty"`
+
+	// RootFs is the tarball the distro is registered from, if it is not registered yet. It is
+	// imported into InstallDir if set (see Distro.Import), or registered otherwise (see Distro.Register).
+	RootFs     string `json:"rootfs,omitempty" yaml:"rootfs,omitempty"`
+	InstallDir string `json:"installDir,omitempty" yaml:"installDir,omitempty"`
+
+	DefaultUID           *uint32 `json:"defaultUID,omitempty" yaml:"defaultUID,omitempty"`
+	InteropEnabled       *bool   `json:"interopEnabled,omitempty" yaml:"interopEnabled,omitempty"`
+	PathAppended         *bool   `json:"pathAppended,omitempty" yaml:"pathAppended,omitempty"`
+	DriveMountingEnabled *bool   `json:"driveMountingEnabled,omitempty" yaml:"driveMountingEnabled,omitempty"`
+
+	// Default means that the distro must be the default one. At most one distro may be the default.
+	Default bool `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// ActionKind is the kind of change an Action makes.
+type ActionKind string
+
+// The kinds of changes Apply can make.
+const (
+	ActionUnregister ActionKind = "unregister"
+	ActionRegister   ActionKind = "register"
+	ActionConfigure  ActionKind = "configure"
+	ActionSetDefault ActionKind = "set-default"
+)
+
+// Action is one of the changes needed to converge a machine to a Manifest.
+type Action struct {
+	Kind   ActionKind `json:"kind" yaml:"kind"`
+	Distro string     `json:"distro" yaml:"distro"`
+	Detail string     `json:"detail,omitempty" yaml:"detail,omitempty"` // Human-readable description of the change
+
+	run func(ctx context.Context) error
+}
+
+// String describes the action in a single line.
+func (a Action) String() string {
+	if a.Detail == "" {
+		return fmt.Sprintf("%s %s", a.Kind, a.Distro)
+	}
+	return fmt.Sprintf("%s %s: %s", a.Kind, a.Distro, a.Detail)
+}
+
+type applyOptions struct {
+	dryRun bool
+}
+
+// WithDryRun is an optional parameter for Apply that makes it compute the plan without
+// making any change.
+func WithDryRun() func(*applyOptions) {
+	return func(o *applyOptions) {
+		o.dryRun = true
+	}
+}
+
+// LoadManifest reads a manifest from a YAML or JSON file. Unknown fields are an error,
+// so that typos are not silently ignored.
+func LoadManifest(path string) (m Manifest, err error) {
+	out, err := os.ReadFile(path)
+	if err != nil {
+		return m, fmt.Errorf("could not read manifest: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(out))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil {
+		return m, fmt.Errorf("could not parse manifest %q: %v", path, err)
+	}
+
+	return m, nil
+}
+
+// Apply converges the distros of the machine to the manifest. The plan is computed first,
+// and then its actions are applied in order: unregistering, registering, configuring and
+// finally setting the default distro. Apply is idempotent: applying the same manifest
+// twice results in an empty plan the second time.
+//
+// It returns the plan. With WithDryRun, nothing is changed. If an action fails, Apply
+// stops and returns the actions that were applied before the failure.
+func Apply(ctx context.Context, m Manifest, opts ...func(*applyOptions)) (plan []Action, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("could not apply manifest: %w", err)
+		}
+	}()
+
+	options := applyOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	plan, err = m.plan()
+	if err != nil {
+		return nil, err
+	}
+
+	if options.dryRun {
+		return plan, nil
+	}
+
+	for i, a := range plan {
+		if err := ctx.Err(); err != nil {
+			return plan[:i], err
+		}
+		if err := a.run(ctx); err != nil {
+			return plan[:i], fmt.Errorf("%s: %w", a, err)
+		}
+	}
+
+	return plan, nil
+}
+
+// validate checks the manifest for inconsistencies that do not depend on the state of the machine.
+func (m Manifest) validate() error {
+	seen := make(map[string]bool)
+	var defaultDistro string
+
+	for _, dm := range m.Distros {
+		if dm.Name == "" {
+			return errors.New("distro without a name")
+		}
+
+		name := strings.ToLower(dm.Name)
+		if seen[name] {
+			return fmt.Errorf("distro %q is listed more than once", dm.Name)
+		}
+		seen[name] = true
+
+		if dm.Absent && (dm != DistroManifest{Name: dm.Name, Absent: true}) {
+			return fmt.Errorf("distro %q must be absent, so it cannot have any other setting", dm.Name)
+		}
+
+		if dm.InstallDir != "" && dm.RootFs == "" {
+			return fmt.Errorf("distro %q has an install directory but no rootfs", dm.Name)
+		}
+
+		if !dm.Default {
+			continue
+		}
+		if defaultDistro != "" {
+			return fmt.Errorf("distros %q and %q cannot both be the default", defaultDistro, dm.Name)
+		}
+		defaultDistro = dm.Name
+	}
+
+	return nil
+}
+
+// plan computes the actions needed to converge the machine to the manifest.
+func (m Manifest) plan() ([]Action, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	distros, err := RegisteredDistros()
+	if err != nil {
+		return nil, err
+	}
+
+	registered := make(map[string]bool)
+	for _, d := range distros {
+		registered[strings.ToLower(d.Name)] = true
+	}
+
+	def, err := DefaultDistro()
+	if err != nil {
+		return nil, err
+	}
+
+	var unregister, register, configure, setDefault []Action
+
+	for _, dm := range m.Distros {
+		dm := dm
+		d := Distro{Name: dm.Name}
+		isRegistered := registered[strings.ToLower(dm.Name)]
+
+		if dm.Absent {
+			if isRegistered {
+				unregister = append(unregister, Action{
+					Kind:   ActionUnregister,
+					Distro: dm.Name,
+					run:    func(ctx context.Context) error { return d.UnregisterContext(ctx) },
+				})
+			}
+			continue
+		}
+
+		var current *Configuration
+		if isRegistered {
+			c, err := d.GetConfiguration()
+			if err != nil {
+				return nil, err
+			}
+			current = &c
+		} else {
+			if dm.RootFs == "" {
+				return nil, fmt.Errorf("distro %q is not registered and has no rootfs to register it from", dm.Name)
+			}
+			register = append(register, dm.registerAction())
+		}
+
+		if changes := dm.configChanges(current); len(changes) != 0 {
+			configure = append(configure, Action{
+				Kind:   ActionConfigure,
+				Distro: dm.Name,
+				Detail: strings.Join(changes, ", "),
+				run: func(context.Context) error {
+					_, err := d.Update(dm.applyConfig)
+					return err
+				},
+			})
+		}
+
+		if dm.Default && !strings.EqualFold(def.Name, dm.Name) {
+			setDefault = append(setDefault, Action{
+				Kind:   ActionSetDefault,
+				Distro: dm.Name,
+				run:    func(ctx context.Context) error { return d.SetAsDefaultContext(ctx) },
+			})
+		}
+	}
+
+	var plan []Action
+	for _, actions := range [][]Action{unregister, register, configure, setDefault} {
+		plan = append(plan, actions...)
+	}
+
+	return plan, nil
+}
+
+// registerAction returns the action that registers the distro from its rootfs.
+func (dm DistroManifest) registerAction() Action {
+	d := Distro{Name: dm.Name}
+
+	if dm.InstallDir == "" {
+		return Action{
+			Kind:   ActionRegister,
+			Distro: dm.Name,
+			Detail: fmt.Sprintf("from %s", dm.RootFs),
+			run:    func(ctx context.Context) error { return d.RegisterContext(ctx, dm.RootFs) },
+		}
+	}
+
+	return Action{
+		Kind:   ActionRegister,
+		Distro: dm.Name,
+		Detail: fmt.Sprintf("from %s into %s", dm.RootFs, dm.InstallDir),
+		run:    func(ctx context.Context) error { return d.Import(ctx, dm.RootFs, dm.InstallDir) },
+	}
+}
+
+// configChanges describes the settings of the manifest that differ from the current
+// configuration, e.g. "DefaultUID: 0 -> 1000". If the distro is not registered yet,
+// current is nil, and all the settings of the manifest are described.
+func (dm DistroManifest) configChanges(current *Configuration) (changes []string) {
+	var from Configuration
+	if current != nil {
+		from = *current
+	}
+	to := from
+	dm.applyConfig(&to)
+
+	for _, s := range []struct {
+		name     string
+		set      bool
+		from, to any
+	}{
+		{"DefaultUID", dm.DefaultUID != nil, from.DefaultUID, to.DefaultUID},
+		{"InteropEnabled", dm.InteropEnabled != nil, from.InteropEnabled, to.InteropEnabled},
+		{"PathAppended", dm.PathAppended != nil, from.PathAppended, to.PathAppended},
+		{"DriveMountingEnabled", dm.DriveMountingEnabled != nil, from.DriveMountingEnabled, to.DriveMountingEnabled},
+	} {
+		switch {
+		case !s.set:
+		case current == nil:
+			changes = append(changes, fmt.Sprintf("%s: %v", s.name, s.to))
+		case s.from != s.to:
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", s.name, s.from, s.to))
+		}
+	}
+
+	return changes
+}
+
+// applyConfig sets the settings of the manifest into the configuration.
+func (dm DistroManifest) applyConfig(c *Configuration) {
+	if dm.DefaultUID != nil {
+		c.DefaultUID = *dm.DefaultUID
+	}
+	if dm.InteropEnabled != nil {
+		c.InteropEnabled = *dm.InteropEnabled
+	}
+	if dm.PathAppended != nil {
+		c.PathAppended = *dm.PathAppended
+	}
+	if dm.DriveMountingEnabled != nil {
+		c.DriveMountingEnabled = *dm.DriveMountingEnabled
+	}
+}
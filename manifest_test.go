@@ -0,0 +1,202 @@
+package wsl_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadManifest(t *testing.T) {
+	t.Parallel()
+
+	want := wsl.Manifest{Distros: []wsl.DistroManifest{
+		{
+			Name:         "Ubuntu",
+			RootFs:       `C:\images\ubuntu.tar.gz`,
+			InstallDir:   `D:\wsl\ubuntu`,
+			DefaultUID:   ptr(uint32(1000)),
+			PathAppended: ptr(false),
+			Default:      true,
+		},
+		{
+			Name:   "Legacy",
+			Absent: true,
+		},
+	}}
+
+	testCases := map[string]struct {
+		fixture string
+
+		wantErr bool
+	}{
+		"From YAML": {fixture: "workstation.yaml"},
+		"From JSON": {fixture: "workstation.json"},
+
+		// Error cases
+		"Error on missing file":  {fixture: "does_not_exist.yaml", wantErr: true},
+		"Error on unknown field": {fixture: "unknown_field.yaml", wantErr: true},
+		"Error on wrong type":    {fixture: "wrong_type.yaml", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wsl.LoadManifest(filepath.Join("testdata", "manifest", tc.fixture))
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success loading manifest")
+				return
+			}
+			require.NoError(t, err, "Unexpected error loading manifest")
+			require.Equal(t, want, got, "Unexpected manifest")
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	testCases := map[string]struct {
+		distros []wsl.DistroManifest
+
+		wantPlan []string
+		wantErr  bool
+	}{
+		"Empty manifest":                {wantPlan: nil},
+		"Distro already in state":       {distros: []wsl.DistroManifest{{Name: "Existing", DefaultUID: ptr(uint32(0)), InteropEnabled: ptr(true), Default: true}}, wantPlan: nil},
+		"Absent distro is already gone": {distros: []wsl.DistroManifest{{Name: "Gone", Absent: true}}, wantPlan: nil},
+		"Names are case-insensitive":    {distros: []wsl.DistroManifest{{Name: "EXISTING", Default: true}}, wantPlan: nil},
+
+		"Unregister a distro": {
+			distros:  []wsl.DistroManifest{{Name: "ToRemove", Absent: true}},
+			wantPlan: []string{"unregister ToRemove"},
+		},
+		"Register a distro": {
+			distros:  []wsl.DistroManifest{{Name: "New", RootFs: "ROOTFS"}},
+			wantPlan: []string{"register New: from ROOTFS"},
+		},
+		"Import a distro": {
+			distros:  []wsl.DistroManifest{{Name: "New", RootFs: "ROOTFS", InstallDir: "INSTALLDIR"}},
+			wantPlan: []string{"register New: from ROOTFS into INSTALLDIR"},
+		},
+		"Configure a distro": {
+			distros:  []wsl.DistroManifest{{Name: "Other", DefaultUID: ptr(uint32(1000)), InteropEnabled: ptr(true), DriveMountingEnabled: ptr(false)}},
+			wantPlan: []string{"configure Other: DefaultUID: 0 -> 1000, DriveMountingEnabled: true -> false"},
+		},
+		"Change the default distro": {
+			distros:  []wsl.DistroManifest{{Name: "Other", Default: true}},
+			wantPlan: []string{"set-default Other"},
+		},
+		"Actions are sorted by kind": {
+			distros: []wsl.DistroManifest{
+				{Name: "New", RootFs: "ROOTFS", PathAppended: ptr(false), Default: true},
+				{Name: "Other", InteropEnabled: ptr(false)},
+				{Name: "ToRemove", Absent: true},
+			},
+			wantPlan: []string{
+				"unregister ToRemove",
+				"register New: from ROOTFS",
+				"configure New: PathAppended: false",
+				"configure Other: InteropEnabled: true -> false",
+				"set-default New",
+			},
+		},
+
+		// Error cases
+		"Error on distro without name":                {distros: []wsl.DistroManifest{{RootFs: "ROOTFS"}}, wantErr: true},
+		"Error on repeated distro":                    {distros: []wsl.DistroManifest{{Name: "Other"}, {Name: "other", Absent: true}}, wantErr: true},
+		"Error on absent distro with settings":        {distros: []wsl.DistroManifest{{Name: "ToRemove", Absent: true, Default: true}}, wantErr: true},
+		"Error on two default distros":                {distros: []wsl.DistroManifest{{Name: "Existing", Default: true}, {Name: "Other", Default: true}}, wantErr: true},
+		"Error on install dir without rootfs":         {distros: []wsl.DistroManifest{{Name: "New", InstallDir: "INSTALLDIR"}}, wantErr: true},
+		"Error on unregistered distro without rootfs": {distros: []wsl.DistroManifest{{Name: "New", DefaultUID: ptr(uint32(1000))}}, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			useFakeBackend(t)
+
+			rootfs := fakeRootFs(t)
+			for _, name := range []string{"Existing", "Other", "ToRemove"} {
+				d := wsl.Distro{Name: name}
+				require.NoError(t, d.Register(rootfs), "Setup: could not register fake distro")
+			}
+
+			// Placeholders make the expected plans independent of the test's temporary directories
+			installDir := t.TempDir()
+			placeholders := map[string]string{"ROOTFS": rootfs, "INSTALLDIR": installDir}
+			for i := range tc.distros {
+				if p, ok := placeholders[tc.distros[i].RootFs]; ok {
+					tc.distros[i].RootFs = p
+				}
+				if p, ok := placeholders[tc.distros[i].InstallDir]; ok {
+					tc.distros[i].InstallDir = p
+				}
+			}
+			for i := range tc.wantPlan {
+				tc.wantPlan[i] = strings.NewReplacer("ROOTFS", rootfs, "INSTALLDIR", installDir).Replace(tc.wantPlan[i])
+			}
+
+			m := wsl.Manifest{Distros: tc.distros}
+			ctx := context.Background()
+
+			before, err := wsl.RegisteredDistroInfo()
+			require.NoError(t, err, "Setup: could not take a snapshot of the distros")
+
+			plan, err := wsl.Apply(ctx, m, wsl.WithDryRun())
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success planning the manifest")
+				return
+			}
+			require.NoError(t, err, "Unexpected error planning the manifest")
+			require.Equal(t, tc.wantPlan, planStrings(plan), "Unexpected plan")
+
+			after, err := wsl.RegisteredDistroInfo()
+			require.NoError(t, err, "Setup: could not take a snapshot of the distros")
+			require.Equal(t, before, after, "A dry run should not change anything")
+
+			applied, err := wsl.Apply(ctx, m)
+			require.NoError(t, err, "Unexpected error applying the manifest")
+			require.Equal(t, tc.wantPlan, planStrings(applied), "Applied actions should match the plan")
+
+			plan, err = wsl.Apply(ctx, m, wsl.WithDryRun())
+			require.NoError(t, err, "Unexpected error planning the manifest after applying it")
+			require.Empty(t, plan, "Applying a manifest should converge the machine to it")
+		})
+	}
+}
+
+func TestApplyFailure(t *testing.T) {
+	useFakeBackend(t)
+
+	m := wsl.Manifest{Distros: []wsl.DistroManifest{
+		{Name: "New", RootFs: fakeRootFs(t)},
+		{Name: "Broken", RootFs: filepath.Join(t.TempDir(), "does_not_exist.tar.gz")},
+	}}
+
+	applied, err := wsl.Apply(context.Background(), m)
+	require.Error(t, err, "Unexpected success applying a manifest with a missing rootfs")
+	require.Equal(t, []string{"register New: from " + m.Distros[0].RootFs}, planStrings(applied), "Only the actions before the failure should be returned")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.Distros = []wsl.DistroManifest{{Name: "New", Absent: true}}
+	applied, err = wsl.Apply(ctx, m)
+	require.ErrorIs(t, err, context.Canceled, "Expected the cancellation of the context to stop Apply")
+	require.Empty(t, applied, "No action should be applied after the context is cancelled")
+}
+
+// planStrings describes each of the actions of a plan.
+func planStrings(plan []wsl.Action) []string {
+	if plan == nil {
+		return nil
+	}
+	s := make([]string, 0, len(plan))
+	for _, a := range plan {
+		s = append(s, a.String())
+	}
+	return s
+}
@@ -0,0 +1,182 @@
+package wsl
+
+// This file contains utilities to take snapshots of distros, and to serialize them as JSON and YAML.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DistroInfo is a snapshot of a distro and its configuration. It can be serialized as JSON
+// or YAML, with stable field names, in order to store and compare the state of a machine.
+type DistroInfo struct {
+	Name          string        `json:"name" yaml:"name"`
+	GUID          string        `json:"guid" yaml:"guid"`
+	State         State         `json:"state" yaml:"state"`
+	Configuration Configuration `json:"configuration" yaml:"configuration"`
+}
+
+// Info takes a snapshot of the distro.
+func (d Distro) Info() (info DistroInfo, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("could not take a snapshot of %q: %w", d.Name, err)
+		}
+	}()
+
+	info.Name = d.Name
+
+	if info.GUID, err = d.GUID(); err != nil {
+		return info, err
+	}
+
+	if info.State, err = d.State(); err != nil {
+		return info, err
+	}
+
+	if info.Configuration, err = d.GetConfiguration(); err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
+
+// RegisteredDistroInfo takes a snapshot of all registered distros, sorted by name.
+func RegisteredDistroInfo() ([]DistroInfo, error) {
+	props, err := RegisteredDistroProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := States()
+	if err != nil {
+		return nil, fmt.Errorf("could not take a snapshot of the registered distros: %w", err)
+	}
+
+	infos := make([]DistroInfo, 0, len(props))
+	for _, p := range props {
+		d := Distro{Name: p.Name}
+
+		conf, err := d.GetConfiguration()
+		if err != nil {
+			return nil, fmt.Errorf("could not take a snapshot of %q: %w", d.Name, err)
+		}
+
+		s, ok := states[p.Name]
+		if !ok {
+			s = NotRegistered
+		}
+
+		infos = append(infos, DistroInfo{
+			Name:          p.Name,
+			GUID:          p.GUID,
+			State:         s,
+			Configuration: conf,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos, nil
+}
+
+// distroData is the serialized form of a Distro. The GUID is only present for pinned distros.
+type distroData struct {
+	Name string `json:"name" yaml:"name"`
+	GUID string `json:"guid,omitempty" yaml:"guid,omitempty"`
+}
+
+// MarshalJSON serializes the distro. Pinned distros (see Distro.Pin) keep their GUID.
+func (d Distro) MarshalJSON() ([]byte, error) {
+	return json.Marshal(distroData{Name: d.Name, GUID: d.guid})
+}
+
+// UnmarshalJSON deserializes the distro. Distros that were pinned when they were serialized
+// are pinned to the same GUID.
+func (d *Distro) UnmarshalJSON(data []byte) error {
+	var dd distroData
+	if err := json.Unmarshal(data, &dd); err != nil {
+		return err
+	}
+	return d.fromData(dd)
+}
+
+// MarshalYAML serializes the distro. Pinned distros (see Distro.Pin) keep their GUID.
+func (d Distro) MarshalYAML() (interface{}, error) {
+	return distroData{Name: d.Name, GUID: d.guid}, nil
+}
+
+// UnmarshalYAML deserializes the distro. Distros that were pinned when they were serialized
+// are pinned to the same GUID.
+func (d *Distro) UnmarshalYAML(node *yaml.Node) error {
+	var dd distroData
+	if err := node.Decode(&dd); err != nil {
+		return err
+	}
+	return d.fromData(dd)
+}
+
+func (d *Distro) fromData(dd distroData) error {
+	if dd.Name == "" {
+		return errors.New("could not deserialize distro: missing name")
+	}
+	if dd.GUID != "" && !guidRegex.MatchString(dd.GUID) {
+		return fmt.Errorf("could not deserialize distro %q: invalid GUID %q", dd.Name, dd.GUID)
+	}
+
+	*d = Distro{Name: dd.Name, guid: dd.GUID}
+	return nil
+}
+
+// configurationData has the same fields (and tags) as Configuration, but none of its methods.
+type configurationData Configuration
+
+// MarshalJSON serializes the configuration. A nil set of environment variables is serialized
+// as an empty one, so that equal configurations are always serialized the same way.
+func (conf Configuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(conf.data())
+}
+
+// UnmarshalJSON deserializes the configuration.
+func (conf *Configuration) UnmarshalJSON(data []byte) error {
+	var cd configurationData
+	if err := json.Unmarshal(data, &cd); err != nil {
+		return err
+	}
+	return conf.fromData(cd)
+}
+
+// MarshalYAML serializes the configuration. A nil set of environment variables is serialized
+// as an empty one, so that equal configurations are always serialized the same way.
+func (conf Configuration) MarshalYAML() (interface{}, error) {
+	return conf.data(), nil
+}
+
+// UnmarshalYAML deserializes the configuration.
+func (conf *Configuration) UnmarshalYAML(node *yaml.Node) error {
+	var cd configurationData
+	if err := node.Decode(&cd); err != nil {
+		return err
+	}
+	return conf.fromData(cd)
+}
+
+func (conf Configuration) data() configurationData {
+	if conf.DefaultEnvironmentVariables == nil {
+		conf.DefaultEnvironmentVariables = map[string]string{}
+	}
+	return configurationData(conf)
+}
+
+func (conf *Configuration) fromData(cd configurationData) error {
+	if cd.WSLVersion > 2 {
+		return fmt.Errorf("could not deserialize configuration: invalid WSL version %d", cd.WSLVersion)
+	}
+
+	*conf = Configuration(cd)
+	return nil
+}
@@ -0,0 +1,190 @@
+package wsl_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMarshalConfiguration(t *testing.T) {
+	t.Parallel()
+
+	config := wsl.Configuration{
+		Version:                     2,
+		DefaultUID:                  1000,
+		InteropEnabled:              true,
+		PathAppended:                false,
+		DriveMountingEnabled:        true,
+		WSLVersion:                  2,
+		DefaultEnvironmentVariables: map[string]string{"LANG": "en_US.UTF-8", "HOSTTYPE": "x86_64"},
+	}
+
+	testCases := map[string]struct {
+		config wsl.Configuration
+
+		wantJSON string
+		wantYAML string
+	}{
+		"Configuration": {
+			config:   config,
+			wantJSON: `{"version":2,"defaultUID":1000,"interopEnabled":true,"pathAppended":false,"driveMountingEnabled":true,"wslVersion":2,"defaultEnvironmentVariables":{"HOSTTYPE":"x86_64","LANG":"en_US.UTF-8"}}`,
+			wantYAML: `version: 2
+defaultUID: 1000
+interopEnabled: true
+pathAppended: false
+driveMountingEnabled: true
+wslVersion: 2
+defaultEnvironmentVariables:
+    HOSTTYPE: x86_64
+    LANG: en_US.UTF-8
+`,
+		},
+		"Empty configuration": {
+			config:   wsl.Configuration{},
+			wantJSON: `{"version":0,"defaultUID":0,"interopEnabled":false,"pathAppended":false,"driveMountingEnabled":false,"wslVersion":0,"defaultEnvironmentVariables":{}}`,
+			wantYAML: `version: 0
+defaultUID: 0
+interopEnabled: false
+pathAppended: false
+driveMountingEnabled: false
+wslVersion: 0
+defaultEnvironmentVariables: {}
+`,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			want := tc.config
+			if want.DefaultEnvironmentVariables == nil {
+				want.DefaultEnvironmentVariables = map[string]string{}
+			}
+
+			gotJSON, err := json.Marshal(tc.config)
+			require.NoError(t, err, "Unexpected error marshalling as JSON")
+			require.Equal(t, tc.wantJSON, string(gotJSON), "Unexpected JSON")
+
+			var fromJSON wsl.Configuration
+			require.NoError(t, json.Unmarshal(gotJSON, &fromJSON), "Unexpected error unmarshalling JSON")
+			require.Equal(t, want, fromJSON, "Configuration did not round-trip through JSON")
+
+			gotYAML, err := yaml.Marshal(tc.config)
+			require.NoError(t, err, "Unexpected error marshalling as YAML")
+			require.Equal(t, tc.wantYAML, string(gotYAML), "Unexpected YAML")
+
+			var fromYAML wsl.Configuration
+			require.NoError(t, yaml.Unmarshal(gotYAML, &fromYAML), "Unexpected error unmarshalling YAML")
+			require.Equal(t, want, fromYAML, "Configuration did not round-trip through YAML")
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		data   string
+		target any
+	}{
+		"Error on Configuration with invalid WSL version": {data: `{"wslVersion": 3}`, target: &wsl.Configuration{}},
+		"Error on Configuration with wrong types":         {data: `{"defaultUID": "root"}`, target: &wsl.Configuration{}},
+		"Error on Distro without a name":                  {data: `{"guid": "{ee8aef7a-846f-4561-a028-79504ce65cd3}"}`, target: &wsl.Distro{}},
+		"Error on Distro with an invalid GUID":            {data: `{"name": "Ubuntu", "guid": "ee8aef7a"}`, target: &wsl.Distro{}},
+		"Error on DistroInfo with an unknown state":       {data: `{"name": "Ubuntu", "state": "Sleeping"}`, target: &wsl.DistroInfo{}},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// JSON is a subset of YAML, so the same data works for both
+			require.Error(t, json.Unmarshal([]byte(tc.data), tc.target), "Unexpected success unmarshalling JSON")
+			require.Error(t, yaml.Unmarshal([]byte(tc.data), tc.target), "Unexpected success unmarshalling YAML")
+		})
+	}
+}
+
+func TestMarshalState(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []wsl.State{wsl.Unknown, wsl.NotRegistered, wsl.Stopped, wsl.Running, wsl.Installing, wsl.Uninstalling, wsl.Converting} {
+		s := s
+		t.Run(s.String(), func(t *testing.T) {
+			t.Parallel()
+
+			out, err := json.Marshal(s)
+			require.NoError(t, err, "Unexpected error marshalling state")
+			require.Equal(t, `"`+s.String()+`"`, string(out), "State should be serialized as its name")
+
+			var got wsl.State
+			require.NoError(t, json.Unmarshal(out, &got), "Unexpected error unmarshalling state")
+			require.Equal(t, s, got, "State did not round-trip")
+		})
+	}
+}
+
+func TestFakeBackendDistroInfo(t *testing.T) {
+	fake := useFakeBackend(t)
+	scriptSleepInfinity(fake)
+
+	d1 := registerFakeDistro(t, "FakeDistro1")
+	require.NoError(t, d1.DefaultUID(1000), "Setup: could not set default user")
+	require.NoError(t, d1.Command(context.Background(), "sleep infinity").Start(), "Setup: could not start a process")
+
+	d2 := registerFakeDistro(t, "FakeDistro0")
+
+	info, err := d1.Info()
+	require.NoError(t, err, "Unexpected error taking a snapshot of the distro")
+	require.Equal(t, "FakeDistro1", info.Name, "Unexpected name")
+	require.Equal(t, wsl.Running, info.State, "Unexpected state")
+	require.Equal(t, uint32(1000), info.Configuration.DefaultUID, "Unexpected default UID")
+
+	guid, err := d1.GUID()
+	require.NoError(t, err, "Setup: could not get GUID of the distro")
+	require.Equal(t, guid, info.GUID, "Unexpected GUID")
+
+	all, err := wsl.RegisteredDistroInfo()
+	require.NoError(t, err, "Unexpected error taking a snapshot of all distros")
+	require.Len(t, all, 2, "Unexpected number of snapshots")
+	require.Equal(t, "FakeDistro0", all[0].Name, "Snapshots should be sorted by name")
+	require.Equal(t, wsl.Stopped, all[0].State, "Unexpected state")
+	require.Equal(t, info, all[1], "Snapshot should be the same as the one taken with Info")
+
+	// Snapshots round-trip
+	out, err := json.Marshal(all)
+	require.NoError(t, err, "Unexpected error marshalling snapshots as JSON")
+	var fromJSON []wsl.DistroInfo
+	require.NoError(t, json.Unmarshal(out, &fromJSON), "Unexpected error unmarshalling snapshots from JSON")
+	require.Equal(t, all, fromJSON, "Snapshots did not round-trip through JSON")
+
+	out, err = yaml.Marshal(all)
+	require.NoError(t, err, "Unexpected error marshalling snapshots as YAML")
+	var fromYAML []wsl.DistroInfo
+	require.NoError(t, yaml.Unmarshal(out, &fromYAML), "Unexpected error unmarshalling snapshots from YAML")
+	require.Equal(t, all, fromYAML, "Snapshots did not round-trip through YAML")
+
+	// Pinned distros keep their pin
+	require.NoError(t, d2.Pin(), "Setup: could not pin distro")
+	out, err = json.Marshal(d2)
+	require.NoError(t, err, "Unexpected error marshalling pinned distro")
+
+	var pinned wsl.Distro
+	require.NoError(t, json.Unmarshal(out, &pinned), "Unexpected error unmarshalling pinned distro")
+	require.Equal(t, d2, pinned, "Pinned distro did not round-trip")
+
+	require.NoError(t, d2.Unregister(), "Setup: could not unregister distro")
+	require.NoError(t, d2.Register(fakeRootFs(t)), "Setup: could not register distro again")
+	_, err = pinned.Info()
+	require.ErrorIs(t, err, wsl.ErrDistroReplaced, "Unmarshalled pinned distro should notice it was replaced")
+
+	_, err = (&wsl.Distro{Name: "NotRegistered"}).Info()
+	require.Error(t, err, "Unexpected success taking a snapshot of an unregistered distro")
+}
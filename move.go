@@ -0,0 +1,215 @@
+package wsl
+
+// This file contains utilities to relocate the filesystem of a distro.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// renameDir is used to move directories. If it fails with errCrossDevice, directories are
+// copied instead. It is a variable so that it can be replaced with a stand-in during tests.
+var renameDir = os.Rename
+
+// Move relocates the directory where the filesystem of the distro is stored (its BasePath,
+// see Properties) into newDir, and updates the distro's registry key accordingly. The
+// distro is terminated first. newDir must not exist, but its parent is created if necessary.
+//
+// The directory is renamed, unless newDir is on another drive, in which case it is copied
+// instead. If any step fails, the completed ones are undone, so that the distro is left where
+// it was. The only exception is removing the old directory after copying it: if that fails,
+// the distro is still moved, and the error says so.
+func (d *Distro) Move(ctx context.Context, newDir string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("could not move %q to %q: %w", d.Name, newDir, err)
+		}
+	}()
+
+	newDir, err = filepath.Abs(filepath.FromSlash(newDir))
+	if err != nil {
+		return err
+	}
+
+	props, err := d.Properties()
+	if err != nil {
+		return err
+	}
+	if props.BasePath == "" {
+		return errors.New("the distro has no install directory")
+	}
+
+	if _, err := os.Lstat(newDir); err == nil {
+		return errors.New("destination already exists")
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newDir), 0755); err != nil {
+		return err
+	}
+
+	var copied bool
+	if err := runMoveSteps(ctx, d.moveSteps(ctx, props.GUID, props.BasePath, newDir, &copied)); err != nil {
+		return err
+	}
+
+	if !copied {
+		return nil
+	}
+
+	if err := os.RemoveAll(props.BasePath); err != nil {
+		return fmt.Errorf("the distro was moved, but its old directory could not be removed: %v", err)
+	}
+
+	return nil
+}
+
+// moveStep is one of the steps needed to move a distro. The undo function reverts the
+// changes made by do, and may be nil if there is nothing to revert.
+type moveStep struct {
+	name string
+	do   func() error
+	undo func() error
+}
+
+// moveSteps returns the sequence of steps that moves the distro with the specified GUID from
+// oldDir into newDir. If the directory is copied rather than renamed, copied is set to true.
+func (d *Distro) moveSteps(ctx context.Context, guid, oldDir, newDir string, copied *bool) []moveStep {
+	return []moveStep{
+		{
+			name: "terminate the distro",
+			do:   func() error { return d.TerminateContext(ctx) },
+		},
+		{
+			name: "move the install directory",
+			do: func() error {
+				err := renameDir(oldDir, newDir)
+				if !errors.Is(err, errCrossDevice) {
+					return err
+				}
+				*copied = true
+				return copyDir(ctx, oldDir, newDir)
+			},
+			undo: func() error {
+				if *copied {
+					return os.RemoveAll(newDir)
+				}
+				return renameDir(newDir, oldDir)
+			},
+		},
+		{
+			name: "update the registry",
+			do:   func() error { return backend.SetBasePath(guid, newDir) },
+			undo: func() error { return backend.SetBasePath(guid, oldDir) },
+		},
+	}
+}
+
+// runMoveSteps runs the steps in order. If one of them fails, the completed steps are
+// undone in reverse order.
+func runMoveSteps(ctx context.Context, steps []moveStep) error {
+	for i, step := range steps {
+		err := ctx.Err()
+		if err == nil {
+			err = step.do()
+		}
+		if err == nil {
+			continue
+		}
+
+		err = fmt.Errorf("could not %s: %w", step.name, err)
+
+		for j := i - 1; j >= 0; j-- {
+			if steps[j].undo == nil {
+				continue
+			}
+			if e := steps[j].undo(); e != nil {
+				return fmt.Errorf("%w. Could not undo step %q: %v", err, steps[j].name, e)
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// copyDir copies the directory src into dst, which must not exist. Only directories and
+// regular files are supported. If the copy fails, dst is removed.
+func copyDir(ctx context.Context, src, dst string) (err error) {
+	defer func() {
+		if err == nil {
+			return
+		}
+		if e := os.RemoveAll(dst); e != nil {
+			err = fmt.Errorf("%w. Could not clean up the partial copy: %v", err, e)
+		}
+	}()
+
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			return os.Mkdir(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			return copyFile(ctx, path, target, info.Mode().Perm())
+		default:
+			return fmt.Errorf("cannot copy %q: not a regular file", path)
+		}
+	})
+}
+
+// copyFile copies the regular file src into dst, stopping early if the context is cancelled.
+func copyFile(ctx context.Context, src, dst string, perm fs.FileMode) (err error) {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := w.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	_, err = io.Copy(w, ctxReader{ctx: ctx, r: r})
+	return err
+}
+
+// ctxReader is a reader that fails once its context is cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
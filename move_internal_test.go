@@ -0,0 +1,109 @@
+package wsl
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveRollback(t *testing.T) {
+	testCases := map[string]struct {
+		failAt      int
+		copyDirs    bool
+		breakCopy   bool
+		breakRename bool
+
+		wantUndoErr bool
+	}{
+		"Failure terminating the distro":      {failAt: 0},
+		"Failure moving the directory":        {failAt: 1},
+		"Failure updating the registry":       {failAt: 2},
+		"Failure after all steps":             {failAt: 3},
+		"Failure copying the directory":       {failAt: 1, copyDirs: true, breakCopy: true},
+		"Failure after copying the directory": {failAt: 3, copyDirs: true},
+		"Failure undoing a step is reported":  {failAt: 3, wantUndoErr: true},
+
+		"Failure renaming within the same drive is not worked around by copying": {failAt: 1, breakRename: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Cleanup(SetBackend(NewFakeBackend()))
+
+			renameErr := errors.New("mock rename error")
+			if tc.copyDirs {
+				renameErr = errCrossDevice
+			}
+			if tc.copyDirs || tc.breakRename {
+				restore := renameDir
+				renameDir = func(oldDir, newDir string) error {
+					return &os.LinkError{Op: "rename", Old: oldDir, New: newDir, Err: renameErr}
+				}
+				t.Cleanup(func() { renameDir = restore })
+			}
+
+			ctx := context.Background()
+
+			rootfs := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+			require.NoError(t, os.WriteFile(rootfs, []byte{}, 0600), "Setup: could not create fake rootfs")
+
+			oldDir := filepath.Join(t.TempDir(), "old")
+			newDir := filepath.Join(t.TempDir(), "new")
+			require.NoError(t, os.MkdirAll(filepath.Join(oldDir, "subdir"), 0700), "Setup: could not create install directory")
+			require.NoError(t, os.WriteFile(filepath.Join(oldDir, "subdir", "ext4.vhdx"), []byte("vhdxfile"), 0600), "Setup: could not create fake VHDX")
+
+			d := Distro{Name: "FakeDistro"}
+			require.NoError(t, d.Import(ctx, rootfs, oldDir), "Setup: could not import fake distro")
+
+			props, err := d.Properties()
+			require.NoError(t, err, "Setup: could not get the properties of the fake distro")
+
+			var copied bool
+			steps := d.moveSteps(ctx, props.GUID, oldDir, newDir, &copied)
+			require.Len(t, steps, 3, "Setup: unexpected number of steps")
+
+			if tc.breakCopy {
+				// Symlinks cannot be copied, so the copy fails after copying the subdirectory
+				if err := os.Symlink("subdir", filepath.Join(oldDir, "zlink")); err != nil {
+					t.Skipf("Setup: could not create symlink: %v", err)
+				}
+			}
+
+			injected := errors.New("mock step error")
+			if tc.failAt == len(steps) {
+				steps = append(steps, moveStep{name: "fail", do: func() error { return injected }})
+			} else if !tc.breakCopy && !tc.breakRename {
+				steps[tc.failAt].do = func() error { return injected }
+			}
+
+			if tc.wantUndoErr {
+				steps[2].undo = func() error { return errors.New("mock undo error") }
+			}
+
+			err = runMoveSteps(ctx, steps)
+			require.Error(t, err, "runMoveSteps should fail when a step fails")
+			if tc.wantUndoErr {
+				require.ErrorContains(t, err, "mock undo error", "Errors undoing steps should be reported")
+				return
+			}
+			if tc.breakRename {
+				require.ErrorIs(t, err, renameErr, "The error renaming the directory should be returned")
+				require.False(t, copied, "The directory should not be copied if it cannot be renamed within the same drive")
+			}
+
+			out, err := os.ReadFile(filepath.Join(oldDir, "subdir", "ext4.vhdx"))
+			require.NoError(t, err, "The install directory should have been restored")
+			require.Equal(t, "vhdxfile", string(out), "The contents of the install directory should not change")
+			require.NoDirExists(t, newDir, "The new directory should have been removed")
+
+			props, err = d.Properties()
+			require.NoError(t, err, "Could not get the properties of the fake distro")
+			require.Equal(t, oldDir, props.BasePath, "The registry should point to the original directory")
+		})
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !plan9
+
+package wsl
+
+import "syscall"
+
+// errCrossDevice is the error of renameDir when the directories are on different devices.
+var errCrossDevice error = syscall.EXDEV
@@ -0,0 +1,7 @@
+package wsl
+
+import "os"
+
+// errCrossDevice is the error of renameDir when the directories are in different parent
+// directories, as Plan 9 cannot rename files into another directory.
+var errCrossDevice error = os.ErrInvalid
@@ -0,0 +1,50 @@
+package wsl_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendMove(t *testing.T) {
+	fake := useFakeBackend(t)
+	ctx := context.Background()
+
+	scriptSleepInfinity(fake)
+
+	oldDir := filepath.Join(t.TempDir(), "old")
+	newDir := filepath.Join(t.TempDir(), "nested", "new")
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	require.Error(t, d.Move(ctx, newDir), "Unexpected success moving an unregistered distro")
+
+	require.NoError(t, d.Import(ctx, fakeRootFs(t), oldDir), "Setup: could not import fake distro")
+	require.NoError(t, os.MkdirAll(oldDir, 0700), "Setup: could not create install directory")
+	require.NoError(t, os.WriteFile(filepath.Join(oldDir, "ext4.vhdx"), []byte("vhdxfile"), 0600), "Setup: could not create fake VHDX")
+
+	require.Error(t, d.Move(ctx, t.TempDir()), "Unexpected success moving a distro into an existing directory")
+
+	cmd := d.Command(ctx, "sleep infinity")
+	require.NoError(t, cmd.Start(), "Setup: could not start command")
+
+	require.NoError(t, d.Move(ctx, newDir), "Unexpected error moving a distro")
+	require.Error(t, cmd.Wait(), "The distro should have been terminated")
+
+	props, err := d.Properties()
+	require.NoError(t, err, "Unexpected error getting the properties of a moved distro")
+	require.Equal(t, newDir, props.BasePath, "The registry should point to the new directory")
+
+	out, err := os.ReadFile(filepath.Join(newDir, "ext4.vhdx"))
+	require.NoError(t, err, "The VHDX should be in the new directory")
+	require.Equal(t, "vhdxfile", string(out), "The VHDX should not change when moved")
+	require.NoDirExists(t, oldDir, "The old directory should have been removed")
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, d.Move(ctx, oldDir), context.Canceled, "Expected the cancellation of the context to stop Move")
+	require.DirExists(t, newDir, "A cancelled move should leave the distro where it was")
+}
@@ -0,0 +1,6 @@
+package wsl
+
+import "golang.org/x/sys/windows"
+
+// errCrossDevice is the error of renameDir when the directories are on different drives.
+var errCrossDevice error = windows.ERROR_NOT_SAME_DEVICE
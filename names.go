@@ -0,0 +1,107 @@
+package wsl
+
+// This file contains utilities to validate the names of distros.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxNameLength is the longest distro name accepted by WSL.
+const maxNameLength = 256
+
+// ErrAlreadyRegistered is returned when creating a distro with the name of a registered one.
+// Like in WSL, names are case-insensitive, so "ubuntu" is taken if "Ubuntu" is registered.
+var ErrAlreadyRegistered = errors.New("already registered")
+
+// InvalidNameError is returned when a distro name does not follow the naming rules of WSL
+// (see ValidateName).
+type InvalidNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidNameError) Error() string {
+	return fmt.Sprintf("invalid distro name %q: %s", e.Name, e.Reason)
+}
+
+// ValidateName checks that name is a valid distro name, returning an *InvalidNameError otherwise.
+// WSL only accepts names made of ASCII letters, digits, and the characters '.', '_' and '-',
+// of at most 256 characters. It does not check whether the name is already in use.
+func ValidateName(name string) error {
+	if name == "" {
+		return &InvalidNameError{Name: name, Reason: "it cannot be empty"}
+	}
+
+	if len(name) > maxNameLength {
+		return &InvalidNameError{Name: name, Reason: fmt.Sprintf("it cannot be longer than %d characters", maxNameLength)}
+	}
+
+	for _, r := range name {
+		if !validNameRune(r) {
+			return &InvalidNameError{Name: name, Reason: fmt.Sprintf("character %q is not allowed: use only letters, digits, '.', '_' and '-'", r)}
+		}
+	}
+
+	return nil
+}
+
+// SanitizeName turns name into a valid distro name (see ValidateName) by replacing path
+// separators with '-' and any other forbidden character with '_', and by truncating it
+// to the maximum length. The result is only invalid if name is empty.
+func SanitizeName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case validNameRune(r):
+			sb.WriteRune(r)
+		case r == '/' || r == '\\':
+			sb.WriteRune('-')
+		default:
+			sb.WriteRune('_')
+		}
+
+		if sb.Len() == maxNameLength {
+			break
+		}
+	}
+
+	return sb.String()
+}
+
+// validNameRune returns true if r is allowed in distro names.
+func validNameRune(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return true
+	case r == '.', r == '_', r == '-':
+		return true
+	}
+	return false
+}
+
+// checkNameAvailable returns an error if the name of the distro is not valid, or if it
+// is already in use by a registered distro.
+func (d Distro) checkNameAvailable() error {
+	if err := ValidateName(d.Name); err != nil {
+		return err
+	}
+
+	distros, err := RegisteredDistros()
+	if err != nil {
+		return fmt.Errorf("failed to detect if it is already installed: %w", err)
+	}
+
+	for _, other := range distros {
+		if !strings.EqualFold(other.Name, d.Name) {
+			continue
+		}
+		if other.Name != d.Name {
+			return fmt.Errorf("%w as %q", ErrAlreadyRegistered, other.Name)
+		}
+		return ErrAlreadyRegistered
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package wsl_test
+
+import (
+	"strings"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		name string
+
+		wantErr bool
+	}{
+		"Letters and digits":           {name: "Ubuntu2204"},
+		"Dots, dashes and underscores": {name: "Ubuntu-22.04_LTS"},
+		"Longest name":                 {name: strings.Repeat("a", 256)},
+
+		// Error cases
+		"Error on empty name":            {name: "", wantErr: true},
+		"Error on name that is too long": {name: strings.Repeat("a", 257), wantErr: true},
+		"Error on whitespace":            {name: "Ubuntu 22.04", wantErr: true},
+		"Error on path separator":        {name: "Ubuntu/22.04", wantErr: true},
+		"Error on null character":        {name: "Ubuntu\x00", wantErr: true},
+		"Error on non-ASCII letter":      {name: "Übuntu", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := wsl.ValidateName(tc.name)
+			if !tc.wantErr {
+				require.NoError(t, err, "Unexpected error validating a valid name")
+				return
+			}
+
+			var target *wsl.InvalidNameError
+			require.ErrorAs(t, err, &target, "Expected an InvalidNameError")
+			require.Equal(t, tc.name, target.Name, "Unexpected name in the error")
+		})
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		name string
+
+		want string
+	}{
+		"Valid name is unchanged":     {name: "Ubuntu-22.04", want: "Ubuntu-22.04"},
+		"Whitespace":                  {name: "Ubuntu 22.04 LTS", want: "Ubuntu_22.04_LTS"},
+		"Path separators":             {name: `TestSomething/sub\case`, want: "TestSomething-sub-case"},
+		"Non-ASCII letters":           {name: "Übuntu", want: "_buntu"},
+		"Null character":              {name: "Ubuntu\x00", want: "Ubuntu_"},
+		"Long names are truncated":    {name: strings.Repeat("a", 300), want: strings.Repeat("a", 256)},
+		"Empty name is still invalid": {name: "", want: ""},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := wsl.SanitizeName(tc.name)
+			require.Equal(t, tc.want, got, "Unexpected sanitized name")
+
+			if tc.name != "" {
+				require.NoError(t, wsl.ValidateName(got), "Sanitized name should be valid")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package wsl
+
+// This file contains utilities to query the properties WSL stores about each distro.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Properties are the properties of a distro, as stored by WSL in the registry under
+// the Lxss key.
+type Properties struct {
+	GUID               string   // Name of the distro's registry key, e.g. {ee8aef7a-846f-4561-a028-79504ce65cd3}
+	Name               string   // Name of the distro
+	BasePath           string   // Windows directory where the distro's filesystem is stored
+	State              uint32   // Registration state of the distro (1 when it is installed)
+	Version            uint32   // Type of filesystem used (lxfs vs. wslfs, relevant only to WSL1)
+	Flags              uint32   // Windows' WSL_DISTRIBUTION_FLAGS, plus the undocumented WSL version bit
+	DefaultUID         uint32   // User ID of default user
+	PackageFamilyName  string   // Package of the store application that installed the distro, if any
+	KernelCommandLine  string   // Command line passed to the kernel
+	DefaultEnvironment []string // Environment variables passed to the distro by default, formatted as VAR=value
+}
+
+// Properties returns the properties WSL stores about the distro in the registry.
+func (d Distro) Properties() (props Properties, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to obtain properties of %q: %w", d.Name, err)
+		}
+	}()
+
+	if d.guid != "" {
+		if err := d.checkPinned(); err != nil {
+			return props, err
+		}
+		return readRegistryDistroProperties(backend.Registry(), d.guid)
+	}
+
+	distros, err := RegisteredDistroProperties()
+	if err != nil {
+		return props, err
+	}
+
+	for _, p := range distros {
+		if p.Name != d.Name {
+			continue
+		}
+		return p, nil
+	}
+
+	return props, errors.New("not registered")
+}
+
+// RegisteredDistroProperties returns the properties of all registered distros. It is
+// equivalent to calling Properties on each of the distros returned by RegisteredDistros,
+// but the registry is only read once.
+func RegisteredDistroProperties() ([]Properties, error) {
+	return registeredDistroProperties(backend.Registry())
+}
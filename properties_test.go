@@ -0,0 +1,41 @@
+package wsl_test
+
+import (
+	"path/filepath"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendProperties(t *testing.T) {
+	useFakeBackend(t)
+	rootfs := fakeRootFs(t)
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	_, err := d.Properties()
+	require.Error(t, err, "Unexpected success getting the properties of an unregistered distro")
+
+	require.NoError(t, d.Register(rootfs), "Setup: could not register fake distro")
+	require.NoError(t, (&wsl.Distro{Name: "OtherFakeDistro"}).Register(rootfs), "Setup: could not register fake distro")
+
+	p, err := d.Properties()
+	require.NoError(t, err, "Unexpected error getting the properties")
+	assert.Regexp(t, `^\{[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\}$`, p.GUID)
+	assert.Equal(t, d.Name, p.Name)
+	assert.Equal(t, filepath.Dir(rootfs), p.BasePath)
+	assert.Equal(t, uint32(1), p.State)
+	assert.Equal(t, uint32(0), p.DefaultUID)
+	assert.Contains(t, p.DefaultEnvironment, "TERM=xterm-256color")
+
+	require.NoError(t, d.DefaultUID(1000), "Setup: could not set DefaultUID")
+	p, err = d.Properties()
+	require.NoError(t, err, "Unexpected error getting the properties")
+	assert.Equal(t, uint32(1000), p.DefaultUID, "Properties should reflect the configuration")
+
+	all, err := wsl.RegisteredDistroProperties()
+	require.NoError(t, err, "Unexpected error getting the properties of all distros")
+	require.Len(t, all, 2, "Unexpected number of distros")
+	assert.NotEqual(t, all[0].GUID, all[1].GUID, "Distros should have different GUIDs")
+}
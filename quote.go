@@ -0,0 +1,131 @@
+package wsl
+
+// This file contains utilities to build the command lines passed to the shell of a distro.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ShellQuote quotes s so that a POSIX shell reads it as a single word with the exact
+// contents of s. Strings that need no quoting are returned unchanged.
+//
+// Note that strings containing NUL characters cannot be passed to a program as arguments.
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	if strings.IndexFunc(s, needsQuoting) == -1 {
+		return s
+	}
+
+	// Single quotes preserve everything but single quotes, which are closed, escaped and reopened
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// needsQuoting returns true if the shell could interpret r as something other than a literal character.
+// This includes '=', which would turn a command name into a variable assignment.
+func needsQuoting(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return false
+	case strings.ContainsRune("@%+:,./_-", r):
+		return false
+	}
+	return true
+}
+
+// shellJoin quotes each of the words and joins them into a single command line.
+func shellJoin(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		quoted = append(quoted, ShellQuote(w))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// commandLine returns the command line that the shell of the distro runs. The command is
+// wrapped in order to apply Dir and Env:
+//
+//	exec env [-i KEEP] NAME=value... /bin/sh -c 'cd -- DIR || exit 1
+//	COMMAND'
+//
+// The directory is changed on a line of its own, so that no part of the command runs if it
+// fails, even if the command is made of several statements. KEEP keeps the variable that
+// Interrupt relies on (see keepMarker). If Env is nil and ReplaceEnv is not set, the command
+// runs in the shell of the distro.
+func (c *Cmd) commandLine() (string, error) {
+	command := c.command
+	if c.Dir != "" {
+		command = fmt.Sprintf("cd -- %s || exit 1\n%s", ShellQuote(c.Dir), command)
+	}
+
+	if c.Env == nil && !c.ReplaceEnv {
+		return command, nil
+	}
+
+	env, err := c.envAssignments()
+	if err != nil {
+		return "", err
+	}
+	args := shellJoin(append(env, "/bin/sh", "-c", command))
+
+	if c.ReplaceEnv {
+		// The marker is expanded by the shell, so it is not quoted like the rest
+		return fmt.Sprintf("exec env -i %s %s", keepMarker, args), nil
+	}
+	return "exec env " + args, nil
+}
+
+// execArgs returns the program and arguments that run the command without a shell (see Exec).
+// The program is wrapped in order to apply Env:
+//
+//	env NAME=value... PROGRAM ARGS...
+//
+// With ReplaceEnv, env is started by /bin/sh so that it keeps the variable that Interrupt relies
+// on (see keepMarker). The shell passes the arguments on as they are, without interpreting them:
+//
+//	/bin/sh -c 'exec env -i KEEP "$@"' sh NAME=value... PROGRAM ARGS...
+//
+// Dir is applied by the backend instead.
+func (c *Cmd) execArgs() ([]string, error) {
+	if c.args == nil {
+		return nil, errors.New("only commands created with CommandArgs can be run with Exec")
+	}
+
+	if c.Env == nil && !c.ReplaceEnv {
+		return c.args, nil
+	}
+
+	env, err := c.envAssignments()
+	if err != nil {
+		return nil, err
+	}
+	args := append(env, c.args...)
+
+	if c.ReplaceEnv {
+		trampoline := fmt.Sprintf(`exec env -i %s "$@"`, keepMarker)
+		return append([]string{"/bin/sh", "-c", trampoline, "sh"}, args...), nil
+	}
+	return append([]string{"env"}, args...), nil
+}
+
+// keepMarker expands into the assignment of processMarkerVar in the shell, if it is set, so that
+// the processes of a command that replaces its environment can still be interrupted.
+var keepMarker = fmt.Sprintf(`${%[1]s+"%[1]s=$%[1]s"}`, processMarkerVar)
+
+// envAssignments returns the NAME=value arguments of env(1) that apply Env, after checking
+// that env cannot mistake them for something else.
+func (c *Cmd) envAssignments() ([]string, error) {
+	env := make([]string, 0, len(c.Env))
+	for _, kv := range c.Env {
+		// Names starting with a dash would be taken as options by env
+		if name, _, ok := strings.Cut(kv, "="); !ok || name == "" || strings.HasPrefix(name, "-") {
+			return nil, fmt.Errorf("invalid environment variable %q: it must be formatted as NAME=value", kv)
+		}
+		env = append(env, kv)
+	}
+	return env, nil
+}
@@ -0,0 +1,174 @@
+package wsl
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandLine(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		cmd Cmd
+
+		want    string
+		wantErr bool
+	}{
+		"Plain command":          {cmd: Cmd{command: "echo $HOME"}, want: "echo $HOME"},
+		"Dir":                    {cmd: Cmd{command: "ls", Dir: "/home/my user"}, want: "cd -- '/home/my user' || exit 1\nls"},
+		"Env":                    {cmd: Cmd{command: "ls", Env: []string{"A=1", "B=it's"}}, want: `exec env 'A=1' 'B=it'\''s' /bin/sh -c ls`},
+		"Empty Env":              {cmd: Cmd{command: "ls", Env: []string{}}, want: "exec env /bin/sh -c ls"},
+		"ReplaceEnv":             {cmd: Cmd{command: "ls", Env: []string{"A=1"}, ReplaceEnv: true}, want: `exec env -i ${GOWSL_PROCESS+"GOWSL_PROCESS=$GOWSL_PROCESS"} 'A=1' /bin/sh -c ls`},
+		"ReplaceEnv without Env": {cmd: Cmd{command: "ls", ReplaceEnv: true}, want: `exec env -i ${GOWSL_PROCESS+"GOWSL_PROCESS=$GOWSL_PROCESS"} /bin/sh -c ls`},
+		"Everything": {
+			cmd:  Cmd{command: "ls $A", Dir: "/tmp", Env: []string{"A=1"}},
+			want: "exec env 'A=1' /bin/sh -c 'cd -- /tmp || exit 1\nls $A'",
+		},
+
+		// Error cases
+		"Error on variable without value":        {cmd: Cmd{command: "ls", Env: []string{"A"}}, wantErr: true},
+		"Error on variable without name":         {cmd: Cmd{command: "ls", Env: []string{"=1"}}, wantErr: true},
+		"Error on variable starting with a dash": {cmd: Cmd{command: "ls", Env: []string{"-u=1"}}, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.cmd.commandLine()
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success building the command line")
+				return
+			}
+			require.NoError(t, err, "Unexpected error building the command line")
+			require.Equal(t, tc.want, got, "Unexpected command line")
+		})
+	}
+}
+
+func TestExecArgs(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"printf", "%s\n", "$HOME"}
+
+	testCases := map[string]struct {
+		cmd Cmd
+
+		want    []string
+		wantErr bool
+	}{
+		"Plain program":          {cmd: Cmd{args: args}, want: args},
+		"Dir is left to wsl.exe": {cmd: Cmd{args: args, Dir: "/tmp"}, want: args},
+		"Env":                    {cmd: Cmd{args: args, Env: []string{"A=1", "B=it's"}}, want: []string{"env", "A=1", "B=it's", "printf", "%s\n", "$HOME"}},
+		"ReplaceEnv":             {cmd: Cmd{args: args, Env: []string{"A=1"}, ReplaceEnv: true}, want: []string{"/bin/sh", "-c", `exec env -i ${GOWSL_PROCESS+"GOWSL_PROCESS=$GOWSL_PROCESS"} "$@"`, "sh", "A=1", "printf", "%s\n", "$HOME"}},
+
+		// Error cases
+		"Error on a command line":                {cmd: Cmd{command: "echo $HOME"}, wantErr: true},
+		"Error on variable starting with a dash": {cmd: Cmd{args: args, Env: []string{"-u=1"}}, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.cmd.execArgs()
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success building the arguments")
+				return
+			}
+			require.NoError(t, err, "Unexpected error building the arguments")
+			require.Equal(t, tc.want, got, "Unexpected arguments")
+		})
+	}
+}
+
+func TestCommandLineInShell(t *testing.T) {
+	t.Parallel()
+
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("This test requires /bin/sh")
+	}
+
+	dir := filepath.Join(t.TempDir(), `it's a "dir" with $pecial chars`)
+	require.NoError(t, os.Mkdir(dir, 0700), "Setup: could not create directory")
+
+	// The command is run by /bin/sh, standing in for the shell of the distro
+	const command = `printf '%s|%s|%s\n' "$A" "${B-unset}" "$(pwd)"`
+
+	testCases := map[string]struct {
+		cmd Cmd
+
+		want string
+	}{
+		"Env is added to the environment": {cmd: Cmd{command: command, Env: []string{"A=it's $HOME"}}, want: "it's $HOME|inherited|"},
+		"Env replaces the environment":    {cmd: Cmd{command: command, Env: []string{"A=1"}, ReplaceEnv: true}, want: "1|unset|"},
+		"Last value wins":                 {cmd: Cmd{command: command, Env: []string{"A=1", "A=2"}}, want: "2|inherited|"},
+		"Dir":                             {cmd: Cmd{command: command, Dir: dir}, want: "|inherited|" + dir},
+		"Everything":                      {cmd: Cmd{command: command, Dir: dir, Env: []string{"A=1"}}, want: "1|inherited|" + dir},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			line, err := tc.cmd.commandLine()
+			require.NoError(t, err, "Unexpected error building the command line")
+
+			sh := exec.Command("/bin/sh", "-c", line)
+			sh.Dir = "/"
+			sh.Env = []string{"B=inherited", "PATH=" + os.Getenv("PATH")}
+
+			out, err := sh.Output()
+			require.NoError(t, err, "Unexpected error running the command line")
+
+			want := tc.want
+			if tc.cmd.Dir == "" {
+				want += "/"
+			}
+			require.Equal(t, want+"\n", string(out), "Unexpected output")
+		})
+	}
+}
+
+func TestCommandLineWithMissingDir(t *testing.T) {
+	t.Parallel()
+
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("This test requires /bin/sh")
+	}
+
+	dir := filepath.Join(t.TempDir(), "missing")
+
+	testCases := map[string]struct {
+		command string
+		env     []string
+	}{
+		"Several statements":          {command: "echo a; echo b"},
+		"Several lines":               {command: "echo a\necho b"},
+		"Several statements with Env": {command: "echo a; echo b", env: []string{"A=1"}},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			line, err := (&Cmd{command: tc.command, Dir: dir, Env: tc.env}).commandLine()
+			require.NoError(t, err, "Unexpected error building the command line")
+
+			sh := exec.Command("/bin/sh", "-c", line)
+			sh.Dir = "/"
+
+			out, err := sh.Output()
+			require.Error(t, err, "Unexpected success running a command in a missing directory")
+			require.Empty(t, string(out), "No part of the command should run if the directory is missing")
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package wsl_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellQuote(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		s string
+
+		want string
+	}{
+		"Empty string":               {s: "", want: "''"},
+		"Plain word is unchanged":    {s: "/usr/bin/file-name_1.0", want: "/usr/bin/file-name_1.0"},
+		"Whitespace":                 {s: "hello world", want: "'hello world'"},
+		"Variables are not expanded": {s: "$HOME", want: "'$HOME'"},
+		"Globs are not expanded":     {s: "*.txt", want: "'*.txt'"},
+		"Command substitution":       {s: "$(rm -rf /)", want: "'$(rm -rf /)'"},
+		"Single quotes":              {s: "it's", want: `'it'\''s'`},
+		"Assignments are quoted":     {s: "FOO=bar", want: "'FOO=bar'"},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, wsl.ShellQuote(tc.s), "Unexpected quoted string")
+		})
+	}
+}
+
+func TestShellQuoteRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("This test requires /bin/sh")
+	}
+
+	// Characters the shell treats specially, plus a few regular and multi-byte ones
+	alphabet := []rune("abcAB09 \t\n'\"`$\\!*?[]{}()<>|&;#~=%@^:,./-_éñ€")
+	r := rand.New(rand.NewSource(42)) //nolint:gosec // Reproducible test data, not security-sensitive
+
+	words := []string{"", "'", "''", `\`, "-n", "--", "a=b"}
+	for i := 0; i < 500; i++ {
+		var sb strings.Builder
+		for j := r.Intn(12); j > 0; j-- {
+			sb.WriteRune(alphabet[r.Intn(len(alphabet))])
+		}
+		words = append(words, sb.String())
+	}
+
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		quoted = append(quoted, wsl.ShellQuote(w))
+	}
+
+	// printf prints every argument followed by a NUL character, so that they can be told apart
+	//nolint:gosec // G204: The point of the test is passing arbitrary input to the shell
+	out, err := exec.Command("/bin/sh", "-c", `printf '%s\000' `+strings.Join(quoted, " ")).Output()
+	require.NoError(t, err, "Unexpected error running the quoted command line")
+
+	got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	require.Equal(t, words, got, "Quoted words did not round-trip through the shell")
+
+	// A command name that looks like an assignment must still be run as a command
+	//nolint:gosec // G204: The point of the test is passing arbitrary input to the shell
+	err = exec.Command("/bin/sh", "-c", wsl.ShellQuote("FOO=bar")).Run()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr, "A quoted assignment should be run as a (missing) command")
+	require.Equal(t, 127, exitErr.ExitCode(), "Unexpected exit code running a missing command")
+}
+
+func TestFakeBackendCommandArgs(t *testing.T) {
+	fake := useFakeBackend(t)
+
+	d := registerFakeDistro(t, "FakeDistro")
+
+	// Scripts are matched against the exact command line, so they show how arguments are quoted
+	fake.Script(`echo 'Hello, world!' '$HOME' 'it'\''s' ''`, wsl.FakeResult("quoted\n", "", 0))
+	fake.Script(`env 'A=1' printenv A`, func(p *wsl.FakeProcess) uint32 {
+		if !p.Exec {
+			return 1
+		}
+		if _, err := fmt.Fprintf(p.Stdout, "exec in %q\n", p.Dir); err != nil {
+			return 1
+		}
+		return 0
+	})
+
+	out, err := d.CommandArgs(context.Background(), "echo", "Hello, world!", "$HOME", "it's", "").Output()
+	require.NoError(t, err, "Unexpected error running a command with arguments")
+	require.Equal(t, "quoted\n", string(out), "Unexpected command line")
+
+	testCases := map[string]struct {
+		dir    string
+		useCWD bool
+
+		want string
+	}{
+		"in the home directory":    {want: `exec in "~"`},
+		"in the current directory": {useCWD: true, want: `exec in ""`},
+		"in a directory":           {dir: "/tmp", want: `exec in "/tmp"`},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run("Exec "+name, func(t *testing.T) {
+			cmd := d.CommandArgs(context.Background(), "printenv", "A")
+			cmd.Exec = true
+			cmd.Env = []string{"A=1"}
+			cmd.Dir = tc.dir
+			cmd.UseCWD = tc.useCWD
+
+			out, err := cmd.Output()
+			require.NoError(t, err, "Unexpected error running a command with Exec")
+			require.Equal(t, tc.want+"\n", string(out), "Unexpected arguments with Exec")
+		})
+	}
+
+	cmd := d.Command(context.Background(), "printenv A")
+	cmd.Exec = true
+	require.Error(t, cmd.Run(), "Unexpected success running a command line with Exec")
+}
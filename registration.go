@@ -4,21 +4,28 @@ package wsl
 // as well as utilities to query this status.
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"syscall"
-	"unsafe"
 )
 
 // Register is a wrapper around Win32's WslRegisterDistribution.
 // It creates a new distro with a copy of the given tarball as
-// its filesystem.
-func (d *Distro) Register(rootFsPath string) (e error) {
+// its filesystem. The name of the distro must be valid (see ValidateName)
+// and not in use.
+func (d *Distro) Register(rootFsPath string) error {
+	return d.RegisterContext(context.Background(), rootFsPath)
+}
+
+// RegisterContext is like Register, but it stops waiting if the context is cancelled,
+// in which case the context's error is returned. WslRegisterDistribution cannot be
+// interrupted, so it keeps running in the background: the distro may end up registered.
+func (d *Distro) RegisterContext(ctx context.Context, rootFsPath string) (e error) {
 	defer func() {
 		if e != nil {
-			e = fmt.Errorf("error registering %q: %v", d.Name, e)
+			e = fmt.Errorf("error registering %q: %w", d.Name, e)
 		}
 	}()
 
@@ -27,48 +34,49 @@ func (d *Distro) Register(rootFsPath string) (e error) {
 		return err
 	}
 
-	r, err := d.IsRegistered()
-	if err != nil {
-		return errors.New("failed to detect if it is already installed")
-	}
-	if r {
-		return errors.New("already registered")
-	}
-
-	distroUTF16, err := syscall.UTF16PtrFromString(d.Name)
-	if err != nil {
-		return errors.New("failed to convert distro name to UTF16")
-	}
-
-	rootFsPathUTF16, err := syscall.UTF16PtrFromString(rootFsPath)
-	if err != nil {
-		return fmt.Errorf("failed to convert rootfs '%q' to UTF16", rootFsPath)
+	if err := d.checkNameAvailable(); err != nil {
+		return err
 	}
 
-	r1, _, _ := wslRegisterDistribution.Call(
-		uintptr(unsafe.Pointer(distroUTF16)),
-		uintptr(unsafe.Pointer(rootFsPathUTF16)))
-
-	if r1 != 0 {
-		return fmt.Errorf("failed syscall to wslRegisterDistribution")
+	b, name := backend, d.Name
+	if err := runWithContext(ctx, func() error { return b.RegisterDistribution(name, rootFsPath) }); err != nil {
+		return err
 	}
 
-	return nil
+	return d.repin()
 }
 
 // RegisteredDistros returns a slice of the registered distros.
 func RegisteredDistros() ([]Distro, error) {
-	return registeredDistros()
+	names, err := registeredDistros(backend.Registry())
+	if err != nil {
+		return nil, err
+	}
+
+	distros := make([]Distro, 0, len(names))
+	for _, name := range names {
+		distros = append(distros, Distro{Name: name})
+	}
+	return distros, nil
 }
 
 // IsRegistered returns a boolean indicating whether a distro is registered or not.
+// A pinned distro is only considered registered if it has not been replaced (see Pin).
 func (d Distro) IsRegistered() (registered bool, e error) {
 	defer func() {
 		if e != nil {
-			e = fmt.Errorf("failed to detect if %q is registered: %v", d.Name, e)
+			e = fmt.Errorf("failed to detect if %q is registered: %w", d.Name, e)
 		}
 	}()
 
+	if d.guid != "" {
+		err := d.checkPinned()
+		if errors.Is(err, ErrDistroReplaced) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
 	distros, err := RegisteredDistros()
 	if err != nil {
 		return false, err
@@ -85,13 +93,24 @@ func (d Distro) IsRegistered() (registered bool, e error) {
 
 // Unregister is a wrapper around Win32's WslUnregisterDistribution.
 // It irreparably destroys a distro and its filesystem.
-func (d *Distro) Unregister() (e error) {
+func (d *Distro) Unregister() error {
+	return d.UnregisterContext(context.Background())
+}
+
+// UnregisterContext is like Unregister, but it stops waiting if the context is cancelled,
+// in which case the context's error is returned. WslUnregisterDistribution cannot be
+// interrupted, so it keeps running in the background: the distro may end up unregistered.
+func (d *Distro) UnregisterContext(ctx context.Context) (e error) {
 	defer func() {
 		if e != nil {
-			e = fmt.Errorf("failed to unregister %q: %v", d.Name, e)
+			e = fmt.Errorf("failed to unregister %q: %w", d.Name, e)
 		}
 	}()
 
+	if err := d.checkPinned(); err != nil {
+		return err
+	}
+
 	r, err := d.IsRegistered()
 	if err != nil {
 		return err
@@ -100,17 +119,8 @@ func (d *Distro) Unregister() (e error) {
 		return errors.New("not registered")
 	}
 
-	distroUTF16, err := syscall.UTF16PtrFromString(d.Name)
-	if err != nil {
-		return errors.New("failed to convert distro name to UTF16")
-	}
-
-	r1, _, _ := wslUnregisterDistribution.Call(uintptr(unsafe.Pointer(distroUTF16)))
-
-	if r1 != 0 {
-		return fmt.Errorf("failed syscall to WslLaunchInteractive")
-	}
-	return nil
+	b, name := backend, d.Name
+	return runWithContext(ctx, func() error { return b.UnregisterDistribution(name) })
 }
 
 // fixPath deals with the fact that WslRegisterDistribuion is
@@ -126,3 +136,24 @@ func fixPath(relative string) (string, error) {
 	}
 	return abs, nil
 }
+
+// runWithContext runs f on a worker goroutine and waits for it to return, unless the
+// context is cancelled first. In that case, f is abandoned and the context's error is
+// returned. It is used for blocking Win32 calls, which cannot be interrupted.
+//
+// As f may outlive the call, it must not access anything the caller may modify afterwards.
+func runWithContext(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,6 +1,10 @@
+//go:build windows
+
 package wsl_test
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
 	"time"
 	"wsl"
@@ -183,3 +187,71 @@ func wslShutdownTimeout(t *testing.T, timeout time.Duration) (cancel func()) {
 		close(stop)
 	}
 }
+
+func TestApplyManifest(t *testing.T) {
+	d := wsl.Distro{Name: uniqueDistroName(t)}
+	defer func() {
+		_ = cleanUpWslInstance(d)
+	}()
+
+	interop := false
+	m := wsl.Manifest{Distros: []wsl.DistroManifest{
+		{Name: d.Name, RootFs: jammyRootFs, InstallDir: t.TempDir(), InteropEnabled: &interop},
+	}}
+
+	ctx := context.Background()
+
+	plan, err := wsl.Apply(ctx, m, wsl.WithDryRun())
+	require.NoError(t, err, "unexpected failure planning the manifest")
+	require.Len(t, plan, 2, "Expected the plan to register and configure the distro")
+
+	_, err = wsl.Apply(ctx, m)
+	require.NoError(t, err, "unexpected failure applying the manifest")
+
+	conf, err := d.GetConfiguration()
+	require.NoError(t, err, "unexpected failure getting the configuration of the distro")
+	require.False(t, conf.InteropEnabled, "InteropEnabled should have been disabled")
+
+	m.Distros = []wsl.DistroManifest{{Name: d.Name, Absent: true}}
+	_, err = wsl.Apply(ctx, m)
+	require.NoError(t, err, "unexpected failure applying the manifest to unregister the distro")
+
+	registered, err := d.IsRegistered()
+	require.NoError(t, err, "unexpected failure in IsRegistered")
+	require.False(t, registered, "Distro should have been unregistered")
+}
+
+func TestRename(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+	newName := uniqueDistroName(t)
+	defer func() {
+		_ = cleanUpWslInstance(wsl.Distro{Name: newName})
+	}()
+
+	guid, err := d.GUID()
+	require.NoError(t, err, "Setup: could not get the GUID of the distro")
+
+	err = d.Rename(newName)
+	require.NoError(t, err, "unexpected failure renaming the distro")
+	require.Equal(t, newName, d.Name, "Distro should be renamed in place")
+
+	byGUID, err := wsl.DistroByGUID(guid)
+	require.NoError(t, err, "unexpected failure finding the distro by GUID")
+	require.Equal(t, newName, byGUID.Name, "Registry should have the new name")
+
+	require.NoError(t, d.Command(context.Background(), "exit 0").Run(), "unexpected failure running a command in the renamed distro")
+}
+
+func TestMove(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+	newDir := filepath.Join(t.TempDir(), "moved")
+
+	err := d.Move(context.Background(), newDir)
+	require.NoError(t, err, "unexpected failure moving the distro")
+
+	props, err := d.Properties()
+	require.NoError(t, err, "unexpected failure getting the properties of the distro")
+	require.Equal(t, newDir, props.BasePath, "Registry should point to the new directory")
+
+	require.NoError(t, d.Command(context.Background(), "exit 0").Run(), "unexpected failure running a command in the moved distro")
+}
@@ -0,0 +1,217 @@
+package wsl
+
+// This file contains utilities to read the registry key where WSL stores
+// information about the registered distros.
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+
+	"github.com/0xrawsec/golang-utils/log"
+)
+
+// lxssPath is the path (relative to HKEY_CURRENT_USER) of the registry key where WSL stores its distros.
+const lxssPath = `Software\Microsoft\Windows\CurrentVersion\Lxss\`
+
+// RegistryReader provides read access to the Lxss registry key and its subkeys.
+// Paths are relative to the Lxss key, which is itself referred to by the empty path.
+// Errors about missing keys or values must satisfy errors.Is(err, fs.ErrNotExist).
+//
+// The Lxss key contains subkeys:
+//   - AppxInstallerCache
+//   - {distro 8-4-4-4-8 code}
+//   - {distro 8-4-4-4-8 code}
+//   - ...
+type RegistryReader interface {
+	// SubKeyNames returns the names of the subkeys of the key at path.
+	SubKeyNames(path string) ([]string, error)
+
+	// StringValue returns the string value with the specified name from the key at path.
+	StringValue(path string, name string) (string, error)
+
+	// IntegerValue returns the integer value with the specified name from the key at path.
+	IntegerValue(path string, name string) (uint64, error)
+
+	// StringsValue returns the multi-string value with the specified name from the key at path.
+	StringsValue(path string, name string) ([]string, error)
+}
+
+// defaultDistro gets the name of the default distribution.
+// If no distros are installed (hence no default), an empty string is returned.
+func defaultDistro(reg RegistryReader) (name string, err error) {
+	defer func() {
+		if err == nil {
+			return
+		}
+		err = fmt.Errorf("failed to obtain default distro: %w", err)
+	}()
+
+	subkeys, err := reg.SubKeyNames("")
+	if err != nil {
+		return "", fmt.Errorf("failed to read lxss registry subkeys: %w", err)
+	}
+
+	if len(subkeys) < 2 {
+		// lxss contains subkeys:
+		// - AppxInstallerCache
+		// - {distro 8-4-4-4-8 code}
+		// - {distro 8-4-4-4-8 code}
+		// - ...
+		// We know there are no distros when there is one or fewer subkeys
+		return "", nil
+	}
+
+	target := "DefaultDistribution"
+	distroDir, err := reg.StringValue("", target)
+	if err != nil {
+		return "", fmt.Errorf("cannot find %s:%s : %w", lxssPath, target, err)
+	}
+
+	return readRegistryDistributionName(reg, distroDir)
+}
+
+// registeredDistros returns a slice of the names of the registered distros.
+//
+// It is analogous to
+//
+//	`wsl.exe --list`
+func registeredDistros(reg RegistryReader) (distros []string, err error) {
+	distros, err = scanDistros(reg, readRegistryDistributionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain list of registered distros: %w", err)
+	}
+	return distros, nil
+}
+
+// registeredDistroProperties returns the properties of all registered distros.
+func registeredDistroProperties(reg RegistryReader) (props []Properties, err error) {
+	props, err = scanDistros(reg, readRegistryDistroProperties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain properties of registered distros: %w", err)
+	}
+	return props, nil
+}
+
+// scanDistros calls read concurrently on each of the distro keys under Lxss, and collects
+// the results. Entries that cannot be read are logged and skipped.
+func scanDistros[T any](reg RegistryReader, read func(reg RegistryReader, registryDir string) (T, error)) ([]T, error) {
+	subkeys, err := reg.SubKeyNames("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lxss registry subkeys: %w", err)
+	}
+
+	type distroErr struct {
+		distro T
+		err    error
+	}
+	ch := make(chan distroErr)
+
+	wg := sync.WaitGroup{}
+	for _, skName := range subkeys {
+		if skName == "AppxInstallerCache" {
+			continue // Not a WSL distro
+		}
+
+		skName := skName
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			distro, err := read(reg, skName)
+
+			if err != nil {
+				ch <- distroErr{err: fmt.Errorf("failed to parse registry entry %s: %v", skName, err)}
+				return
+			}
+			ch <- distroErr{distro: distro}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	// Collecting results
+	var distros []T
+	for d := range ch {
+		if d.err != nil {
+			log.Warnf("%v", d.err)
+			continue
+		}
+		distros = append(distros, d.distro)
+	}
+
+	return distros, nil
+}
+
+// readRegistryDistroProperties reads all the values WSL stores in the registry key of a distro.
+// Only DistributionName is required: missing values are left empty, as not all distros have
+// them (for instance, PackageFamilyName is only set for distros installed from the store).
+func readRegistryDistroProperties(reg RegistryReader, registryDir string) (p Properties, err error) {
+	p.GUID = registryDir
+
+	if p.Name, err = readRegistryDistributionName(reg, registryDir); err != nil {
+		return p, err
+	}
+
+	for target, dst := range map[string]*string{
+		"BasePath":          &p.BasePath,
+		"PackageFamilyName": &p.PackageFamilyName,
+		"KernelCommandLine": &p.KernelCommandLine,
+	} {
+		v, err := reg.StringValue(registryDir, target)
+		if err := optionalRegistryValue(registryDir, target, err); err != nil {
+			return p, err
+		}
+		*dst = v
+	}
+
+	for target, dst := range map[string]*uint32{
+		"State":      &p.State,
+		"Version":    &p.Version,
+		"Flags":      &p.Flags,
+		"DefaultUid": &p.DefaultUID,
+	} {
+		v, err := reg.IntegerValue(registryDir, target)
+		if err := optionalRegistryValue(registryDir, target, err); err != nil {
+			return p, err
+		}
+		*dst = uint32(v)
+	}
+
+	target := "DefaultEnvironment"
+	p.DefaultEnvironment, err = reg.StringsValue(registryDir, target)
+	if err := optionalRegistryValue(registryDir, target, err); err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// optionalRegistryValue filters out the error caused by a missing registry value.
+func optionalRegistryValue(registryDir string, target string, err error) error {
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return fmt.Errorf("cannot read %s%s:%s : %w", lxssPath, registryDir, target, err)
+}
+
+// readRegistryDistributionName returs the value of DistributionName from a registry path.
+//
+// An example registry path may be
+//
+//	`Software\Microsoft\Windows\CurrentVersion\Lxss\{ee8aef7a-846f-4561-a028-79504ce65cd3}`.
+//
+// Then, the registryDir is
+//
+//	`{ee8aef7a-846f-4561-a028-79504ce65cd3}`
+func readRegistryDistributionName(reg RegistryReader, registryDir string) (string, error) {
+	target := "DistributionName"
+	name, err := reg.StringValue(registryDir, target)
+	if err != nil {
+		return "", fmt.Errorf("cannot find %s%s:%s : %w", lxssPath, registryDir, target, err)
+	}
+	return name, nil
+}
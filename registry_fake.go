@@ -0,0 +1,262 @@
+package wsl
+
+// This file contains an in-memory RegistryReader, which can be loaded from a fixture file.
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FakeRegistry is an in-memory RegistryReader that emulates the Lxss registry key.
+// Like the Windows registry, key and value names are case-insensitive.
+type FakeRegistry struct {
+	mu   sync.RWMutex
+	root *fakeRegistryKey
+}
+
+// fakeRegistryKey is a key in the FakeRegistry. Values are of type string (REG_SZ),
+// uint64 (REG_DWORD or REG_QWORD) or []string (REG_MULTI_SZ).
+type fakeRegistryKey struct {
+	name    string
+	subkeys map[string]*fakeRegistryKey // Indexed by lowercase name
+	values  map[string]any              // Indexed by lowercase name
+}
+
+// NewFakeRegistry creates a FakeRegistry with an empty Lxss key.
+func NewFakeRegistry() *FakeRegistry {
+	return &FakeRegistry{root: newFakeRegistryKey("Lxss")}
+}
+
+// LoadFakeRegistry creates a FakeRegistry from a JSON or YAML fixture. The fixture
+// is a tree of nested mappings, each representing a key under Lxss. Any other entry
+// is a value: strings are REG_SZ, non-negative integers are REG_DWORD and lists of
+// strings are REG_MULTI_SZ. For instance:
+//
+//	DefaultDistribution: "{ee8aef7a-846f-4561-a028-79504ce65cd3}"
+//	AppxInstallerCache: {}
+//	"{ee8aef7a-846f-4561-a028-79504ce65cd3}":
+//	  DistributionName: Ubuntu
+//	  Version: 2
+//	  DefaultEnvironment: ["HOSTTYPE=x86_64", "LANG=en_US.UTF-8"]
+func LoadFakeRegistry(path string) (*FakeRegistry, error) {
+	out, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read registry fixture: %v", err)
+	}
+
+	var data map[string]any
+	if err := yaml.Unmarshal(out, &data); err != nil {
+		return nil, fmt.Errorf("could not parse registry fixture %q: %v", path, err)
+	}
+
+	r := NewFakeRegistry()
+	if err := r.root.load(data); err != nil {
+		return nil, fmt.Errorf("could not load registry fixture %q: %v", path, err)
+	}
+
+	return r, nil
+}
+
+// SubKeyNames returns the names of the subkeys of the key at path, in alphabetical order.
+func (r *FakeRegistry) SubKeyNames(path string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, err := r.key(path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(key.subkeys))
+	for _, sk := range key.subkeys {
+		names = append(names, sk.name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// StringValue returns the string value with the specified name from the key at path.
+func (r *FakeRegistry) StringValue(path string, name string) (string, error) {
+	v, err := r.value(path, name)
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("value %q has type %T, not a string", name, v)
+	}
+	return s, nil
+}
+
+// IntegerValue returns the integer value with the specified name from the key at path.
+func (r *FakeRegistry) IntegerValue(path string, name string) (uint64, error) {
+	v, err := r.value(path, name)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(uint64)
+	if !ok {
+		return 0, fmt.Errorf("value %q has type %T, not an integer", name, v)
+	}
+	return n, nil
+}
+
+// StringsValue returns the multi-string value with the specified name from the key at path.
+func (r *FakeRegistry) StringsValue(path string, name string) ([]string, error) {
+	v, err := r.value(path, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := v.([]string)
+	if !ok {
+		return nil, fmt.Errorf("value %q has type %T, not a list of strings", name, v)
+	}
+	return append([]string{}, s...), nil
+}
+
+// value returns the value with the specified name from the key at path.
+func (r *FakeRegistry) value(path string, name string) (any, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, err := r.key(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := key.values[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("value %q not found in key %s%s: %w", name, lxssPath, path, fs.ErrNotExist)
+	}
+	return v, nil
+}
+
+// setValue creates or overrides a value in the key at path.
+func (r *FakeRegistry) setValue(path string, name string, value any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, err := r.key(path)
+	if err != nil {
+		return err
+	}
+
+	key.values[strings.ToLower(name)] = value
+	return nil
+}
+
+// deleteValue removes a value from the key at path. It is not an error if the value does not exist.
+func (r *FakeRegistry) deleteValue(path string, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, err := r.key(path)
+	if err != nil {
+		return err
+	}
+
+	delete(key.values, strings.ToLower(name))
+	return nil
+}
+
+// createKey creates a subkey of the Lxss key, unless it already exists.
+func (r *FakeRegistry) createKey(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.root.createSubKey(name)
+}
+
+// deleteKey removes a subkey of the Lxss key, along with all its contents.
+func (r *FakeRegistry) deleteKey(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.root.subkeys[strings.ToLower(name)]; !ok {
+		return fmt.Errorf("cannot find key %s%s: %w", lxssPath, name, fs.ErrNotExist)
+	}
+
+	delete(r.root.subkeys, strings.ToLower(name))
+	return nil
+}
+
+// key finds the key at path. The mutex must be held by the caller.
+func (r *FakeRegistry) key(path string) (*fakeRegistryKey, error) {
+	key := r.root
+	for _, name := range strings.Split(path, `\`) {
+		if name == "" {
+			continue
+		}
+
+		sk, ok := key.subkeys[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("cannot find key %s%s: %w", lxssPath, path, fs.ErrNotExist)
+		}
+		key = sk
+	}
+	return key, nil
+}
+
+func newFakeRegistryKey(name string) *fakeRegistryKey {
+	return &fakeRegistryKey{
+		name:    name,
+		subkeys: make(map[string]*fakeRegistryKey),
+		values:  make(map[string]any),
+	}
+}
+
+// createSubKey returns the subkey with the specified name, creating it if needed.
+func (k *fakeRegistryKey) createSubKey(name string) *fakeRegistryKey {
+	if sk, ok := k.subkeys[strings.ToLower(name)]; ok {
+		return sk
+	}
+
+	sk := newFakeRegistryKey(name)
+	k.subkeys[strings.ToLower(name)] = sk
+	return sk
+}
+
+// load populates the key with the subkeys and values parsed from a fixture.
+func (k *fakeRegistryKey) load(data map[string]any) error {
+	for name, v := range data {
+		switch v := v.(type) {
+		case map[string]any:
+			if err := k.createSubKey(name).load(v); err != nil {
+				return fmt.Errorf("%s\\%v", name, err)
+			}
+		case string:
+			k.values[strings.ToLower(name)] = v
+		case int:
+			if v < 0 {
+				return fmt.Errorf("%s: negative integers are not supported", name)
+			}
+			k.values[strings.ToLower(name)] = uint64(v)
+		case []any:
+			s := make([]string, 0, len(v))
+			for _, item := range v {
+				str, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("%s: lists may only contain strings", name)
+				}
+				s = append(s, str)
+			}
+			k.values[strings.ToLower(name)] = s
+		case nil:
+			return fmt.Errorf("%s: empty values are not supported", name)
+		default:
+			return fmt.Errorf("%s: unsupported type %T", name, v)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package wsl
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisteredDistrosFromRegistry(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		fixture string
+
+		want    []string
+		wantErr bool
+	}{
+		"two distros":                        {fixture: "two_distros.yaml", want: []string{"Ubuntu", "Debian"}},
+		"AppxInstallerCache is not a distro": {fixture: "only_appx_cache.yaml", want: nil},
+		"broken entries are skipped":         {fixture: "broken_entry.json", want: []string{"Ubuntu"}},
+		"no default distro is not an error":  {fixture: "no_default.yaml", want: []string{"Ubuntu"}},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := loadRegistryFixture(t, tc.fixture)
+
+			got, err := registeredDistros(reg)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success listing the registered distros")
+				return
+			}
+			require.NoError(t, err, "Unexpected error listing the registered distros")
+			require.ElementsMatch(t, tc.want, got, "Unexpected list of registered distros")
+		})
+	}
+}
+
+func TestRegisteredDistroPropertiesFromRegistry(t *testing.T) {
+	t.Parallel()
+
+	ubuntu := Properties{
+		GUID:               "{ee8aef7a-846f-4561-a028-79504ce65cd3}",
+		Name:               "Ubuntu",
+		BasePath:           `C:\Users\user\AppData\Local\Packages\CanonicalGroupLimited.Ubuntu_79rhkp1fndgsc\LocalState`,
+		State:              1,
+		Version:            2,
+		Flags:              15,
+		DefaultUID:         1000,
+		PackageFamilyName:  "CanonicalGroupLimited.Ubuntu_79rhkp1fndgsc",
+		KernelCommandLine:  "BOOT_IMAGE=/kernel init=/init",
+		DefaultEnvironment: []string{"HOSTTYPE=x86_64", "LANG=en_US.UTF-8"},
+	}
+
+	debian := Properties{
+		GUID:    "{0b2c5d8e-9b1e-4f3a-a7c6-2d8e1f4b5a6c}",
+		Name:    "Debian",
+		State:   1,
+		Version: 1,
+		Flags:   7,
+	}
+
+	testCases := map[string]struct {
+		fixture string
+
+		want []Properties
+	}{
+		"all values and missing optional values": {fixture: "two_distros.yaml", want: []Properties{ubuntu, debian}},
+		"AppxInstallerCache is not a distro":     {fixture: "only_appx_cache.yaml", want: nil},
+		"entries without a name are skipped":     {fixture: "broken_entry.json", want: []Properties{{GUID: ubuntu.GUID, Name: "Ubuntu", Version: 2}}},
+		"entries with wrong value types are skipped": {
+			fixture: "wrong_value_types.yaml",
+			want:    []Properties{{GUID: ubuntu.GUID, Name: "Ubuntu", Version: 2}},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := loadRegistryFixture(t, tc.fixture)
+
+			got, err := registeredDistroProperties(reg)
+			require.NoError(t, err, "Unexpected error reading the properties of the registered distros")
+			require.ElementsMatch(t, tc.want, got, "Unexpected properties")
+		})
+	}
+}
+
+func TestDefaultDistroFromRegistry(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		fixture string
+
+		want    string
+		wantErr bool
+	}{
+		"default distro":                         {fixture: "two_distros.yaml", want: "Ubuntu"},
+		"fewer than two subkeys means no distro": {fixture: "only_appx_cache.yaml", want: ""},
+
+		// Error cases
+		"error with no DefaultDistribution value":  {fixture: "no_default.yaml", wantErr: true},
+		"error when the default distro is missing": {fixture: "default_not_found.yaml", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := loadRegistryFixture(t, tc.fixture)
+
+			got, err := defaultDistro(reg)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success obtaining the default distro")
+				return
+			}
+			require.NoError(t, err, "Unexpected error obtaining the default distro")
+			require.Equal(t, tc.want, got, "Unexpected default distro")
+		})
+	}
+}
+
+func TestLoadFakeRegistry(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		fixture string
+
+		wantErr bool
+	}{
+		"YAML fixture": {fixture: "two_distros.yaml"},
+		"JSON fixture": {fixture: "broken_entry.json"},
+
+		// Error cases
+		"error with a missing fixture":        {fixture: "does_not_exist.yaml", wantErr: true},
+		"error with a negative integer":       {fixture: "negative_integer.yaml", wantErr: true},
+		"error with a fixture that is a list": {fixture: "not_a_registry.yaml", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadFakeRegistry(filepath.Join("testdata", "registry", tc.fixture))
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success loading the registry fixture")
+				return
+			}
+			require.NoError(t, err, "Unexpected error loading the registry fixture")
+		})
+	}
+}
+
+func TestFakeRegistryIsCaseInsensitive(t *testing.T) {
+	t.Parallel()
+
+	reg := loadRegistryFixture(t, "two_distros.yaml")
+
+	name, err := reg.StringValue("{EE8AEF7A-846F-4561-A028-79504CE65CD3}", "distributionname")
+	require.NoError(t, err, "Unexpected error reading a value with a different casing")
+	require.Equal(t, "Ubuntu", name, "Unexpected value")
+
+	subkeys, err := reg.SubKeyNames("")
+	require.NoError(t, err, "Unexpected error reading the subkeys")
+	require.Contains(t, subkeys, "AppxInstallerCache", "Subkeys should keep their original casing")
+}
+
+// loadRegistryFixture loads a FakeRegistry from testdata/registry.
+func loadRegistryFixture(t *testing.T, fixture string) *FakeRegistry {
+	t.Helper()
+
+	reg, err := LoadFakeRegistry(filepath.Join("testdata", "registry", fixture))
+	require.NoError(t, err, "Setup: could not load registry fixture")
+	return reg
+}
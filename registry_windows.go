@@ -0,0 +1,86 @@
+package wsl
+
+// This file contains the RegistryReader used by the default backend.
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// windowsRegistry reads (and, for a few values, writes) the Lxss key from the Windows registry.
+type windowsRegistry struct{}
+
+// SubKeyNames returns the names of the subkeys of the key at path.
+func (windowsRegistry) SubKeyNames(path string) ([]string, error) {
+	key, err := registry.OpenKey(lxssRegistry, lxssPath+path, registry.READ)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open registry key %s%s: %w", lxssPath, path, err)
+	}
+	defer key.Close()
+
+	keyData, err := key.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat registry key %s%s: %w", lxssPath, path, err)
+	}
+
+	return key.ReadSubKeyNames(int(keyData.SubKeyCount))
+}
+
+// StringValue returns the string value with the specified name from the key at path.
+func (windowsRegistry) StringValue(path string, name string) (string, error) {
+	key, err := registry.OpenKey(lxssRegistry, lxssPath+path, registry.QUERY_VALUE)
+	if err != nil {
+		return "", fmt.Errorf("cannot find key %s%s: %w", lxssPath, path, err)
+	}
+	defer key.Close()
+
+	value, _, err := key.GetStringValue(name)
+	return value, err
+}
+
+// IntegerValue returns the integer value (REG_DWORD or REG_QWORD) with the specified name from the key at path.
+func (windowsRegistry) IntegerValue(path string, name string) (uint64, error) {
+	key, err := registry.OpenKey(lxssRegistry, lxssPath+path, registry.QUERY_VALUE)
+	if err != nil {
+		return 0, fmt.Errorf("cannot find key %s%s: %w", lxssPath, path, err)
+	}
+	defer key.Close()
+
+	value, _, err := key.GetIntegerValue(name)
+	return value, err
+}
+
+// StringsValue returns the multi-string value (REG_MULTI_SZ) with the specified name from the key at path.
+func (windowsRegistry) StringsValue(path string, name string) ([]string, error) {
+	key, err := registry.OpenKey(lxssRegistry, lxssPath+path, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find key %s%s: %w", lxssPath, path, err)
+	}
+	defer key.Close()
+
+	value, _, err := key.GetStringsValue(name)
+	return value, err
+}
+
+// setStringValue writes the string value (REG_SZ) with the specified name into the key at path.
+func (windowsRegistry) setStringValue(path string, name string, value string) error {
+	key, err := registry.OpenKey(lxssRegistry, lxssPath+path, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("cannot find key %s%s: %w", lxssPath, path, err)
+	}
+	defer key.Close()
+
+	return key.SetStringValue(name, value)
+}
+
+// setStringsValue writes the multi-string value (REG_MULTI_SZ) with the specified name into the key at path.
+func (windowsRegistry) setStringsValue(path string, name string, value []string) error {
+	key, err := registry.OpenKey(lxssRegistry, lxssPath+path, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("cannot find key %s%s: %w", lxssPath, path, err)
+	}
+	defer key.Close()
+
+	return key.SetStringsValue(name, value)
+}
@@ -0,0 +1,54 @@
+package wsl
+
+// This file contains utilities to rename distros.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Rename changes the name of the distro to newName, which must be valid (see ValidateName)
+// and not in use by another distro. Only the DistributionName value of the distro's registry
+// key is changed, so its filesystem is left untouched. The distro cannot be running.
+//
+// The distro is updated in place. If it is pinned, it stays pinned to the same GUID, but any
+// other Distro pinned to it fails with ErrDistroReplaced from then on (see Pin).
+func (d *Distro) Rename(newName string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("could not rename %q to %q: %w", d.Name, newName, err)
+		}
+	}()
+
+	if err := ValidateName(newName); err != nil {
+		return err
+	}
+
+	// Changing the case of the name must not fail because the distro itself uses it
+	if !strings.EqualFold(newName, d.Name) {
+		if err := (Distro{Name: newName}).checkNameAvailable(); err != nil {
+			return err
+		}
+	}
+
+	guid, err := d.GUID()
+	if err != nil {
+		return err
+	}
+
+	s, err := d.State()
+	if err != nil {
+		return err
+	}
+	if s == Running {
+		return errors.New("the distro is running: terminate it first")
+	}
+
+	if err := backend.SetDistributionName(guid, newName); err != nil {
+		return err
+	}
+
+	d.Name = newName
+	return nil
+}
@@ -0,0 +1,58 @@
+package wsl_test
+
+import (
+	"context"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendRename(t *testing.T) {
+	fake := useFakeBackend(t)
+	rootfs := fakeRootFs(t)
+
+	scriptSleepInfinity(fake)
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	require.Error(t, d.Rename("NewName"), "Unexpected success renaming an unregistered distro")
+
+	require.NoError(t, d.Register(rootfs), "Setup: could not register fake distro")
+	other := wsl.Distro{Name: "OtherFakeDistro"}
+	require.NoError(t, other.Register(rootfs), "Setup: could not register fake distro")
+
+	require.NoError(t, d.Pin(), "Setup: could not pin fake distro")
+	guid, err := d.GUID()
+	require.NoError(t, err, "Setup: could not get the GUID of the fake distro")
+	stale := d
+
+	var nameErr *wsl.InvalidNameError
+	require.ErrorAs(t, d.Rename("New name"), &nameErr, "Unexpected success renaming a distro to an invalid name")
+	require.ErrorIs(t, d.Rename(other.Name), wsl.ErrAlreadyRegistered, "Unexpected success renaming a distro to the name of another one")
+	require.ErrorIs(t, d.Rename("otherfakedistro"), wsl.ErrAlreadyRegistered, "Unexpected success renaming a distro to the name of another one in different case")
+
+	cmd := d.Command(context.Background(), "sleep infinity")
+	require.NoError(t, cmd.Start(), "Setup: could not start command")
+	require.Error(t, d.Rename("NewName"), "Unexpected success renaming a running distro")
+	require.NoError(t, d.Terminate(), "Setup: could not terminate distro")
+	require.Error(t, cmd.Wait(), "Setup: command should have been terminated")
+	require.Equal(t, "FakeDistro", d.Name, "A failed rename should not change the name")
+
+	require.NoError(t, d.Rename("NewName"), "Unexpected error renaming a distro")
+	require.Equal(t, "NewName", d.Name, "The distro should be renamed in place")
+
+	list, err := wsl.RegisteredDistros()
+	require.NoError(t, err, "Unexpected error listing registered distros")
+	require.ElementsMatch(t, []string{"NewName", "OtherFakeDistro"}, []string{list[0].Name, list[1].Name}, "Unexpected registered distros")
+
+	gotGUID, err := d.GUID()
+	require.NoError(t, err, "A renamed pinned distro should stay pinned")
+	require.Equal(t, guid, gotGUID, "Renaming should not change the GUID")
+	require.NoError(t, d.Terminate(), "Unexpected error using a renamed distro")
+
+	_, err = stale.GUID()
+	require.ErrorIs(t, err, wsl.ErrDistroReplaced, "Other handles pinned to the renamed distro should be invalidated")
+
+	require.NoError(t, d.Rename("NEWNAME"), "Unexpected error changing the case of the name of a distro")
+	require.Equal(t, "NEWNAME", d.Name, "The distro should be renamed in place")
+}
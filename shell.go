@@ -1,10 +1,9 @@
 package wsl
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"syscall"
-	"unsafe"
 )
 
 type shellOptions struct {
@@ -35,7 +34,14 @@ func WithCommand(cmd string) func(*shellOptions) {
 // session is started. This is a synchronous, blocking call.
 //
 // Can be used with optional helper parameters UseCWD and WithCommand.
-func (d *Distro) Shell(opts ...func(*shellOptions)) (err error) {
+func (d *Distro) Shell(opts ...func(*shellOptions)) error {
+	return d.ShellContext(context.Background(), opts...)
+}
+
+// ShellContext is like Shell, but it stops waiting if the context is cancelled, in which
+// case the context's error is returned. WslLaunchInteractive cannot be interrupted, so the
+// shell keeps running (and attached to the console) until it exits or the distro is terminated.
+func (d *Distro) ShellContext(ctx context.Context, opts ...func(*shellOptions)) (err error) {
 	defer func() {
 		if err == nil {
 			return
@@ -43,9 +49,13 @@ func (d *Distro) Shell(opts ...func(*shellOptions)) (err error) {
 		if errors.Is(err, ExitError{}) {
 			return
 		}
-		err = fmt.Errorf("error in Shell with distro %q: %v", d.Name, err)
+		err = fmt.Errorf("error in Shell with distro %q: %w", d.Name, err)
 	}()
 
+	if err := d.checkPinned(); err != nil {
+		return err
+	}
+
 	r, err := d.IsRegistered()
 	if err != nil {
 		return err
@@ -62,31 +72,14 @@ func (d *Distro) Shell(opts ...func(*shellOptions)) (err error) {
 		o(&options)
 	}
 
-	distroUTF16, err := syscall.UTF16PtrFromString(d.Name)
-	if err != nil {
-		return errors.New("failed to convert distro name to UTF16")
-	}
-
-	commandUTF16, err := syscall.UTF16PtrFromString(options.command)
-	if err != nil {
-		return fmt.Errorf("failed to convert command %q to UTF16", options.command)
-	}
-
-	var useCwd wBOOL
-	if options.useCWD {
-		useCwd = 1
-	}
-
 	var exitCode uint32
-
-	r1, _, _ := wslLaunchInteractive.Call(
-		uintptr(unsafe.Pointer(distroUTF16)),
-		uintptr(unsafe.Pointer(commandUTF16)),
-		uintptr(useCwd),
-		uintptr(unsafe.Pointer(&exitCode)))
-
-	if r1 != 0 {
-		return fmt.Errorf("failed syscall to WslLaunchInteractive")
+	b, name := backend, d.Name
+	err = runWithContext(ctx, func() (err error) {
+		exitCode, err = b.LaunchInteractive(name, options.command, options.useCWD)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
 	if exitCode == WindowsError {
@@ -0,0 +1,182 @@
+package wsl
+
+// This file contains utilities to query whether distros are running.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// State is the state of a distro, as reported by `wsl.exe --list --verbose`.
+type State int
+
+// The states a distro can be in.
+const (
+	Unknown       State = iota // The state could not be interpreted (for instance, it was localized)
+	NotRegistered              // The distro is not registered
+	Stopped                    // The distro is registered but not running
+	Running                    // The distro is running
+	Installing                 // The distro is being registered
+	Uninstalling               // The distro is being unregistered
+	Converting                 // The distro is being converted between WSL1 and WSL2
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case Unknown:
+		return "Unknown"
+	case NotRegistered:
+		return "NotRegistered"
+	case Stopped:
+		return "Stopped"
+	case Running:
+		return "Running"
+	case Installing:
+		return "Installing"
+	case Uninstalling:
+		return "Uninstalling"
+	case Converting:
+		return "Converting"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// MarshalText serializes the state as its name, so that it can be stored as JSON or YAML.
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses the name of a state, as serialized by MarshalText.
+func (s *State) UnmarshalText(text []byte) error {
+	for st := Unknown; st <= Converting; st++ {
+		if st.String() == string(text) {
+			*s = st
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown state %q", text)
+}
+
+// State returns the state of the distro. Unregistered distros are NotRegistered.
+func (d Distro) State() (State, error) {
+	return d.StateContext(context.Background())
+}
+
+// StateContext is like State, but wsl.exe is killed if the context is
+// cancelled before it finishes, in which case the context's error is returned.
+func (d Distro) StateContext(ctx context.Context) (s State, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to obtain state of %q: %w", d.Name, err)
+		}
+	}()
+
+	if err := d.checkPinned(); err != nil {
+		return Unknown, err
+	}
+
+	states, err := StatesContext(ctx)
+	if err != nil {
+		return Unknown, err
+	}
+
+	// Distro names are case-insensitive, so the name may not match the key exactly
+	for name, s := range states {
+		if strings.EqualFold(name, d.Name) {
+			return s, nil
+		}
+	}
+	return NotRegistered, nil
+}
+
+// States returns the state of all registered distros, indexed by name.
+//
+// It is analogous to
+//
+//	`wsl.exe --list --verbose`
+func States() (map[string]State, error) {
+	return StatesContext(context.Background())
+}
+
+// StatesContext is like States, but wsl.exe is killed if the context is
+// cancelled before it finishes, in which case the context's error is returned.
+func StatesContext(ctx context.Context) (map[string]State, error) {
+	return backend.States(ctx)
+}
+
+// parseListVerbose parses the output of `wsl.exe --list --verbose`, which may be encoded as
+// UTF-8 or UTF-16LE. It looks like:
+//
+//	  NAME            STATE           VERSION
+//	* Ubuntu          Running         2
+//	  Debian          Stopped         1
+//
+// where the asterisk marks the default distro. The columns are found from the position of
+// the headers, so that localized states containing spaces are parsed correctly.
+func parseListVerbose(out []byte) (map[string]State, error) {
+	lines := strings.Split(strings.ReplaceAll(decodeWSLExeOutput(out), "\r", ""), "\n")
+
+	// Skip anything before the header
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("empty output")
+	}
+
+	columns := columnStarts([]rune(lines[0]))
+	if len(columns) != 3 {
+		return nil, fmt.Errorf("could not parse header %q: expected 3 columns", lines[0])
+	}
+	stateCol, versionCol := columns[1], columns[2]
+
+	states := make(map[string]State)
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		l := []rune(line)
+		if len(l) <= versionCol {
+			return nil, fmt.Errorf("could not parse line %q: too short", line)
+		}
+
+		// The first two characters contain the default distro marker
+		name := strings.TrimSpace(string(l[2:stateCol]))
+		state := strings.TrimSpace(string(l[stateCol:versionCol]))
+		if name == "" || state == "" {
+			return nil, fmt.Errorf("could not parse line %q: missing name or state", line)
+		}
+
+		states[name] = parseState(state)
+	}
+
+	return states, nil
+}
+
+// columnStarts returns the positions where each of the words in a header line start.
+func columnStarts(header []rune) (starts []int) {
+	for i, r := range header {
+		if unicode.IsSpace(r) || r == '*' {
+			continue
+		}
+		if i == 0 || unicode.IsSpace(header[i-1]) {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// parseState converts a state from `wsl.exe --list --verbose` into a State.
+func parseState(s string) State {
+	for _, state := range []State{Stopped, Running, Installing, Uninstalling, Converting} {
+		if s == state.String() {
+			return state
+		}
+	}
+	return Unknown
+}
@@ -0,0 +1,69 @@
+package wsl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListVerbose(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		fixture string
+
+		want    map[string]State
+		wantErr bool
+	}{
+		"UTF-16 output": {
+			fixture: "english_utf16.txt",
+			want:    map[string]State{"Ubuntu": Running, "Debian": Stopped, "docker-desktop-data": Stopped},
+		},
+		"UTF-8 output with transitional states": {
+			fixture: "transitional_states_utf8.txt",
+			want:    map[string]State{"Ubuntu": Running, "Ubuntu-22.04": Installing, "Debian": Uninstalling, "openSUSE-Leap": Converting},
+		},
+		"localized output": {
+			fixture: "spanish_utf16.txt",
+			want:    map[string]State{"Ubuntu": Unknown, "Debian": Unknown},
+		},
+
+		// Error cases
+		"error with no distros message": {fixture: "no_distros_utf16.txt", wantErr: true},
+		"error with a truncated line":   {fixture: "truncated_line_utf8.txt", wantErr: true},
+		"error with empty output":       {fixture: "empty.txt", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			out, err := os.ReadFile(filepath.Join("testdata", "list_verbose", tc.fixture))
+			require.NoError(t, err, "Setup: could not read fixture")
+
+			got, err := parseListVerbose(out)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success parsing the output of wsl.exe --list --verbose")
+				return
+			}
+			require.NoError(t, err, "Unexpected error parsing the output of wsl.exe --list --verbose")
+			require.Equal(t, tc.want, got, "Unexpected states")
+		})
+	}
+}
+
+func TestListVerbose(t *testing.T) {
+	out, err := os.ReadFile(filepath.Join("testdata", "list_verbose", "english_utf16.txt"))
+	require.NoError(t, err, "Setup: could not read fixture")
+
+	recordedArgs := useStandInWslExe(t, standIn{stdout: string(out)})
+
+	got, err := listVerbose(context.Background())
+	require.NoError(t, err, "Unexpected error listing distros")
+	require.Equal(t, []string{"--list", "--verbose"}, recordedArgs(), "Unexpected arguments passed to wsl.exe")
+	require.Equal(t, map[string]State{"Ubuntu": Running, "Debian": Stopped, "docker-desktop-data": Stopped}, got, "Unexpected states")
+}
@@ -0,0 +1,54 @@
+package wsl_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendState(t *testing.T) {
+	fake := useFakeBackend(t)
+
+	scriptSleepInfinity(fake)
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	state, err := d.State()
+	require.NoError(t, err, "Unexpected error getting the state of an unregistered distro")
+	require.Equal(t, wsl.NotRegistered, state, "Unexpected state of an unregistered distro")
+
+	require.NoError(t, d.Register(fakeRootFs(t)), "Setup: could not register fake distro")
+	other := registerFakeDistro(t, "OtherFakeDistro")
+
+	state, err = d.State()
+	require.NoError(t, err, "Unexpected error getting the state of a distro")
+	require.Equal(t, wsl.Stopped, state, "Unexpected state of a distro that was never started")
+
+	cmd := d.Command(context.Background(), "sleep infinity")
+	require.NoError(t, cmd.Start(), "Setup: could not start command")
+
+	states, err := wsl.States()
+	require.NoError(t, err, "Unexpected error getting the states of all distros")
+	require.Equal(t, map[string]wsl.State{d.Name: wsl.Running, other.Name: wsl.Stopped}, states, "Unexpected states")
+
+	state, err = (&wsl.Distro{Name: strings.ToLower(d.Name)}).State()
+	require.NoError(t, err, "Unexpected error getting the state of a distro with a name in another case")
+	require.Equal(t, wsl.Running, state, "Distro names should be case-insensitive")
+
+	require.NoError(t, d.Terminate(), "Setup: could not terminate distro")
+	require.Error(t, cmd.Wait(), "Setup: command should have been terminated")
+
+	state, err = d.State()
+	require.NoError(t, err, "Unexpected error getting the state of a distro")
+	require.Equal(t, wsl.Stopped, state, "Unexpected state of a terminated distro")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = d.StateContext(ctx)
+	require.ErrorIs(t, err, context.Canceled, "Expected StateContext to fail with a cancelled context")
+	_, err = wsl.StatesContext(ctx)
+	require.ErrorIs(t, err, context.Canceled, "Expected StatesContext to fail with a cancelled context")
+}
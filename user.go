@@ -0,0 +1,47 @@
+package wsl
+
+// This file contains utilities to run commands as a specific Linux user.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// UnknownUserError is returned when starting a command as a user that does not exist
+// in the distro (see Cmd.User).
+type UnknownUserError struct {
+	User string
+}
+
+func (e *UnknownUserError) Error() string {
+	return fmt.Sprintf("unknown user %q", e.User)
+}
+
+// resolveUser returns the name of the Linux user, which is specified by name or by UID. The
+// user database of the distro is queried by running id as the default user, except for root.
+func (d *Distro) resolveUser(ctx context.Context, user string) (string, error) {
+	if user == "root" || user == "0" {
+		return "root", nil
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	out, err := d.CommandArgs(ctx, "id", "-nu", "--", user).Output()
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) && exitErr.Code == 1 {
+		return "", &UnknownUserError{User: user}
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not look up user %q: %w", user, err)
+	}
+
+	name := strings.TrimSpace(string(out))
+	if name == "" {
+		return "", fmt.Errorf("could not look up user %q: id returned no name", user)
+	}
+	return name, nil
+}
@@ -0,0 +1,68 @@
+package wsl_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendCommandUser(t *testing.T) {
+	fake := useFakeBackend(t)
+
+	d := registerFakeDistro(t, "FakeDistro")
+	require.NoError(t, d.DefaultUID(1000), "Setup: could not set DefaultUID")
+
+	// Users are looked up as the default user, so that UIDs can be used too
+	fake.Script("id -nu -- ubuntu", wsl.FakeResult("ubuntu\n", "", 0))
+	fake.Script("id -nu -- 1000", wsl.FakeResult("ubuntu\n", "", 0))
+	fake.Script("id -nu -- nobody-here", wsl.FakeResult("", "id: 'nobody-here': no such user\n", 1))
+	fake.Script("whoami", func(p *wsl.FakeProcess) uint32 {
+		user := p.User
+		if user == "" {
+			user = "default"
+		}
+		if _, err := io.WriteString(p.Stdout, user); err != nil {
+			return 1
+		}
+		return 0
+	})
+
+	testCases := map[string]struct {
+		user string
+
+		want    string
+		wantErr bool
+	}{
+		"Default user":          {want: "default"},
+		"User name":             {user: "ubuntu", want: "ubuntu"},
+		"UID":                   {user: "1000", want: "ubuntu"},
+		"Root by name":          {user: "root", want: "root"},
+		"Root by UID":           {user: "0", want: "root"},
+		"Error on unknown user": {user: "nobody-here", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			cmd := d.Command(context.Background(), "whoami")
+			cmd.User = tc.user
+
+			out, err := cmd.Output()
+			if tc.wantErr {
+				var target *wsl.UnknownUserError
+				require.ErrorAs(t, err, &target, "Expected an UnknownUserError")
+				require.Equal(t, tc.user, target.User, "Unexpected user in the error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error running a command as a user")
+			require.Equal(t, tc.want, string(out), "Command ran as the wrong user")
+		})
+	}
+
+	conf, err := d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error reading the configuration")
+	require.Equal(t, uint32(1000), conf.DefaultUID, "Running a command as a user should not change the default user")
+}
@@ -0,0 +1,57 @@
+package wsl
+
+// This file contains utilities to convert distros between WSL1 and WSL2.
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+type setVersionOptions struct {
+	progress io.Writer
+}
+
+// WithProgress is an optional parameter for SetVersion that streams the progress reported by
+// wsl.exe into w.
+func WithProgress(w io.Writer) func(*setVersionOptions) {
+	return func(o *setVersionOptions) {
+		o.progress = w
+	}
+}
+
+// SetVersion converts the distro to the specified WSL version (1 or 2). The distro is
+// terminated in the process. Nothing is done if the distro already has that version.
+//
+// It is analogous to
+//
+//	`wsl.exe --set-version <distro> <version>`
+//
+// The conversion may take several minutes. It stops if the context is cancelled.
+func (d *Distro) SetVersion(ctx context.Context, version uint8, opts ...func(*setVersionOptions)) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error setting WSL version of %q to %d: %w", d.Name, version, err)
+		}
+	}()
+
+	if version != 1 && version != 2 {
+		return fmt.Errorf("unknown WSL version %d", version)
+	}
+
+	options := setVersionOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	conf, err := d.GetConfiguration()
+	if err != nil {
+		return err
+	}
+
+	if conf.WSLVersion == version {
+		return nil
+	}
+
+	return backend.SetVersion(ctx, d.Name, version, options.progress)
+}
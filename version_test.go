@@ -0,0 +1,43 @@
+package wsl_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBackendSetVersion(t *testing.T) {
+	useFakeBackend(t)
+	ctx := context.Background()
+
+	d := wsl.Distro{Name: "FakeDistro"}
+	require.Error(t, d.SetVersion(ctx, 1), "Unexpected success setting the version of an unregistered distro")
+
+	require.NoError(t, d.Register(fakeRootFs(t)), "Setup: could not register fake distro")
+
+	c, err := d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration")
+	require.Equal(t, uint8(2), c.WSLVersion, "Registered distros should be WSL2")
+
+	var progress bytes.Buffer
+	require.NoError(t, d.SetVersion(ctx, 1, wsl.WithProgress(&progress)), "Unexpected error setting the WSL version")
+	require.NotEmpty(t, progress.String(), "Expected progress to be reported")
+
+	c, err = d.GetConfiguration()
+	require.NoError(t, err, "Unexpected error getting the configuration")
+	require.Equal(t, uint8(1), c.WSLVersion, "Unexpected WSL version after calling SetVersion")
+	require.True(t, c.InteropEnabled, "SetVersion should not change the rest of the configuration")
+
+	progress.Reset()
+	require.NoError(t, d.SetVersion(ctx, 1, wsl.WithProgress(&progress)), "Unexpected error setting the same WSL version")
+	require.Empty(t, progress.String(), "Setting the same version should be a no-op")
+
+	require.Error(t, d.SetVersion(ctx, 3), "Unexpected success setting an unknown WSL version")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, d.SetVersion(cancelled, 2), context.Canceled, "Expected the conversion to be cancelled")
+}
@@ -0,0 +1,27 @@
+package wsl_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetVersion(t *testing.T) {
+	d := newTestDistro(t, jammyRootFs)
+
+	for _, version := range []uint8{1, 2} {
+		var progress bytes.Buffer
+		err := d.SetVersion(context.Background(), version, wsl.WithProgress(&progress))
+		require.NoErrorf(t, err, "unexpected failure in SetVersion: %s", progress.String())
+
+		c, err := d.GetConfiguration()
+		require.NoError(t, err, "unexpected failure in GetConfiguration")
+		require.Equal(t, version, c.WSLVersion, "Unexpected WSL version after calling SetVersion")
+	}
+
+	err := (&wsl.Distro{Name: "IAmNotRegistered"}).SetVersion(context.Background(), 2)
+	require.Error(t, err, "unexpected success setting the version of an unregistered distro")
+}
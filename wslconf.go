@@ -0,0 +1,134 @@
+package wsl
+
+// This file contains utilities to read and write the per-distro configuration file /etc/wsl.conf.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	wslConfPath = "/etc/wsl.conf"
+
+	// Commands used to read and write wsl.conf. Reading a missing file is not an error,
+	// and writing goes through a temporary file so that the original is never left truncated.
+	wslConfReadCommand  = "if [ -e /etc/wsl.conf ]; then cat /etc/wsl.conf; fi"
+	wslConfWriteCommand = "cat > /etc/wsl.conf.tmp && mv -f /etc/wsl.conf.tmp /etc/wsl.conf"
+)
+
+// WSLConf is the contents of a distro's /etc/wsl.conf. Only the most common settings are
+// typed. Any other key, as well as comments and formatting, is preserved when the file
+// is written back. Nil and empty fields are removed from the file.
+type WSLConf struct {
+	Systemd           *bool  // [boot] systemd: whether systemd runs as PID 1
+	AutomountRoot     string // [automount] root: directory where Windows drives are mounted
+	AutomountOptions  string // [automount] options: mount options for Windows drives
+	GenerateHosts     *bool  // [network] generateHosts: whether WSL generates /etc/hosts
+	Hostname          string // [network] hostname: hostname of the distro
+	AppendWindowsPath *bool  // [interop] appendWindowsPath: whether the Windows PATH is appended to $PATH
+	DefaultUser       string // [user] default: user to log in as
+
+	doc *iniFile // Document the configuration was parsed from
+}
+
+// fields returns the typed fields of the configuration, in the order they are
+// added to a new file.
+func (c *WSLConf) fields() []iniField {
+	return []iniField{
+		boolField("boot", "systemd", &c.Systemd),
+		stringField("automount", "root", &c.AutomountRoot),
+		stringField("automount", "options", &c.AutomountOptions),
+		boolField("network", "generateHosts", &c.GenerateHosts),
+		stringField("network", "hostname", &c.Hostname),
+		boolField("interop", "appendWindowsPath", &c.AppendWindowsPath),
+		stringField("user", "default", &c.DefaultUser),
+	}
+}
+
+// ParseWSLConf parses the contents of a wsl.conf file.
+func ParseWSLConf(data []byte) (conf WSLConf, err error) {
+	doc, err := parseINI(data)
+	if err != nil {
+		return conf, fmt.Errorf("could not parse wsl.conf: %v", err)
+	}
+
+	if err := doc.loadFields(conf.fields()); err != nil {
+		return conf, fmt.Errorf("could not parse wsl.conf: %v", err)
+	}
+	conf.doc = doc
+
+	return conf, nil
+}
+
+// Bytes serializes the configuration in the wsl.conf format. Lines of the parsed file
+// whose setting did not change are written as they were. Values containing line breaks,
+// or quotes as well as surrounding spaces or comment characters, cannot be written.
+func (c WSLConf) Bytes() ([]byte, error) {
+	doc := newINIFile()
+	var prev WSLConf
+	if c.doc != nil {
+		doc = c.doc.clone()
+		_ = doc.loadFields(prev.fields()) // It was already loaded successfully when parsing
+	}
+
+	doc.storeFields(c.fields(), prev.fields())
+
+	out, err := doc.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize wsl.conf: %v", err)
+	}
+	return out, nil
+}
+
+// WSLConf reads the distro's /etc/wsl.conf. If the file does not exist, an empty
+// configuration is returned.
+func (d Distro) WSLConf(ctx context.Context) (conf WSLConf, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("could not read %s from distro %q: %w", wslConfPath, d.Name, err)
+		}
+	}()
+
+	out, err := d.Command(ctx, wslConfReadCommand).Output()
+	if err != nil {
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) != 0 {
+			return conf, fmt.Errorf("%w: %s", err, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return conf, err
+	}
+
+	return ParseWSLConf(out)
+}
+
+// SetWSLConf overwrites the distro's /etc/wsl.conf. The file is written by root, regardless
+// of the default user. The new settings take effect the next time the distro starts (see
+// Terminate).
+func (d *Distro) SetWSLConf(ctx context.Context, conf WSLConf) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("could not write %s into distro %q: %w", wslConfPath, d.Name, err)
+		}
+	}()
+
+	data, err := conf.Bytes()
+	if err != nil {
+		return err
+	}
+
+	cmd := d.Command(ctx, wslConfWriteCommand)
+	cmd.User = "root"
+	cmd.Stdin = bytes.NewReader(data)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if out = bytes.TrimSpace(out); len(out) != 0 {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package wsl_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"wsl"
+
+	"github.com/stretchr/testify/require"
+)
+
+const sampleWSLConf = `# This file was written by hand
+[boot]
+systemd=true
+
+[automount]
+root = /mnt/  # Where Windows drives go
+options = "metadata,uid=1000;umask=22"
+mountFsTab = false
+
+[network]
+generateHosts = False
+hostname = ubuntu
+
+; Unknown section
+[custom]
+key = value
+
+[user]
+default = ubuntu
+`
+
+func TestParseWSLConf(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		data string
+
+		want    wsl.WSLConf
+		wantErr bool
+	}{
+		"Empty file":                        {data: "", want: wsl.WSLConf{}},
+		"Only comments":                     {data: "# Nothing to see here\n\n; Nothing at all\n", want: wsl.WSLConf{}},
+		"Windows line endings":              {data: "[boot]\r\nsystemd = false\r\n", want: wsl.WSLConf{Systemd: ptr(false)}},
+		"Case-insensitive names":            {data: "[Interop]\nAppendWindowsPath = TRUE\n", want: wsl.WSLConf{AppendWindowsPath: ptr(true)}},
+		"Last repeated key wins":            {data: "[user]\ndefault = root\n[user]\ndefault = ubuntu\n", want: wsl.WSLConf{DefaultUser: "ubuntu"}},
+		"Keys outside sections are ignored": {data: "default = root\n", want: wsl.WSLConf{}},
+		"Full file": {data: sampleWSLConf, want: wsl.WSLConf{
+			Systemd:          ptr(true),
+			AutomountRoot:    "/mnt/",
+			AutomountOptions: "metadata,uid=1000;umask=22",
+			GenerateHosts:    ptr(false),
+			Hostname:         "ubuntu",
+			DefaultUser:      "ubuntu",
+		}},
+
+		// Error cases
+		"Error on line without equals sign":  {data: "[boot]\nsystemd\n", wantErr: true},
+		"Error on unterminated header":       {data: "[boot\nsystemd = true\n", wantErr: true},
+		"Error on empty section name":        {data: "[ ]\n", wantErr: true},
+		"Error on empty key":                 {data: "[boot]\n= true\n", wantErr: true},
+		"Error on unterminated quoted value": {data: "[user]\ndefault = \"root\n", wantErr: true},
+		"Error on non-boolean typed value":   {data: "[boot]\nsystemd = maybe\n", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wsl.ParseWSLConf([]byte(tc.data))
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success parsing wsl.conf")
+				return
+			}
+			require.NoError(t, err, "Unexpected error parsing wsl.conf")
+
+			require.Equal(t, tc.want.Systemd, got.Systemd, "Unexpected value for Systemd")
+			require.Equal(t, tc.want.AutomountRoot, got.AutomountRoot, "Unexpected value for AutomountRoot")
+			require.Equal(t, tc.want.AutomountOptions, got.AutomountOptions, "Unexpected value for AutomountOptions")
+			require.Equal(t, tc.want.GenerateHosts, got.GenerateHosts, "Unexpected value for GenerateHosts")
+			require.Equal(t, tc.want.Hostname, got.Hostname, "Unexpected value for Hostname")
+			require.Equal(t, tc.want.AppendWindowsPath, got.AppendWindowsPath, "Unexpected value for AppendWindowsPath")
+			require.Equal(t, tc.want.DefaultUser, got.DefaultUser, "Unexpected value for DefaultUser")
+		})
+	}
+}
+
+func TestWSLConfBytes(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		data   string
+		update func(*wsl.WSLConf)
+
+		want    string
+		wantErr bool
+	}{
+		"Unchanged file is written as is":       {data: sampleWSLConf, want: sampleWSLConf},
+		"Unchanged empty file":                  {data: "", want: ""},
+		"Boolean spelling is kept if unchanged": {data: "[boot]\nsystemd = TRUE\n", update: func(c *wsl.WSLConf) { c.Systemd = ptr(true) }, want: "[boot]\nsystemd = TRUE\n"},
+
+		"Change a value": {
+			data:   "[user]\n# Comment\ndefault=root # Trailing comment\n",
+			update: func(c *wsl.WSLConf) { c.DefaultUser = "ubuntu" },
+			want:   "[user]\n# Comment\ndefault = ubuntu\n",
+		},
+		"Change a boolean": {
+			data:   "[boot]\nsystemd = true\ncommand = echo hi\n",
+			update: func(c *wsl.WSLConf) { c.Systemd = ptr(false) },
+			want:   "[boot]\nsystemd = false\ncommand = echo hi\n",
+		},
+		"Quote values that need it": {
+			data:   "[automount]\noptions = metadata\n",
+			update: func(c *wsl.WSLConf) { c.AutomountOptions = "metadata;umask=22" },
+			want:   "[automount]\noptions = \"metadata;umask=22\"\n",
+		},
+		"Add a key to an existing section": {
+			data:   "[network]\ngenerateResolvConf = false\n\n[user]\ndefault = root\n",
+			update: func(c *wsl.WSLConf) { c.Hostname = "devbox" },
+			want:   "[network]\ngenerateResolvConf = false\nhostname = devbox\n\n[user]\ndefault = root\n",
+		},
+		"Add a new section": {
+			data:   "# My config\n[user]\ndefault = root\n",
+			update: func(c *wsl.WSLConf) { c.AppendWindowsPath = ptr(false) },
+			want:   "# My config\n[user]\ndefault = root\n\n[interop]\nappendWindowsPath = false\n",
+		},
+		"Remove a key": {
+			data:   "[automount]\nroot = /win/\noptions = metadata\n",
+			update: func(c *wsl.WSLConf) { c.AutomountRoot = "" },
+			want:   "[automount]\noptions = metadata\n",
+		},
+		"Remove repeated keys": {
+			data:   "[user]\ndefault = root\n[user]\ndefault = ubuntu\n",
+			update: func(c *wsl.WSLConf) { c.DefaultUser = "" },
+			want:   "[user]\n[user]\n",
+		},
+		"Write a new file": {
+			update: func(c *wsl.WSLConf) { *c = wsl.WSLConf{Systemd: ptr(true), DefaultUser: "ubuntu"} },
+			want:   "[boot]\nsystemd = true\n\n[user]\ndefault = ubuntu\n",
+		},
+		"Quotes are not quoted if there is no need": {
+			update: func(c *wsl.WSLConf) { c.AutomountOptions = `uid=1000;fmask="11"` },
+			want:   "[automount]\noptions = uid=1000;fmask=\"11\"\n",
+		},
+
+		// Error cases
+		"Error on a value starting with quotes":          {update: func(c *wsl.WSLConf) { c.Hostname = `"x"` }, wantErr: true},
+		"Error on a value with quotes and a comment":     {update: func(c *wsl.WSLConf) { c.AutomountOptions = `fmask="11" #22` }, wantErr: true},
+		"Error on a value with quotes and spaces around": {update: func(c *wsl.WSLConf) { c.AutomountRoot = ` /mnt/"c" ` }, wantErr: true},
+		"Error on a value with a line break":             {update: func(c *wsl.WSLConf) { c.Hostname = "dev\n[boot]" }, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			conf, err := wsl.ParseWSLConf([]byte(tc.data))
+			require.NoError(t, err, "Setup: could not parse wsl.conf")
+
+			if tc.update != nil {
+				tc.update(&conf)
+			}
+
+			out, err := conf.Bytes()
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success serializing wsl.conf")
+				return
+			}
+			require.NoError(t, err, "Unexpected error serializing wsl.conf")
+			got := string(out)
+			require.Equal(t, tc.want, got, "Unexpected serialized wsl.conf")
+
+			// Serializing must not change the configuration
+			out, err = conf.Bytes()
+			require.NoError(t, err, "Unexpected error serializing wsl.conf twice")
+			require.Equal(t, got, string(out), "Serializing twice should give the same result")
+
+			reparsed, err := wsl.ParseWSLConf([]byte(got))
+			require.NoError(t, err, "Serialized wsl.conf should be parseable")
+			require.Equal(t, conf.AutomountOptions, reparsed.AutomountOptions, "Values should round-trip")
+			out, err = reparsed.Bytes()
+			require.NoError(t, err, "Unexpected error serializing the reparsed wsl.conf")
+			require.Equal(t, got, string(out), "Serialized wsl.conf should round-trip")
+		})
+	}
+}
+
+// ptr returns a pointer to a copy of v.
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestFakeBackendWSLConf(t *testing.T) {
+	fake := useFakeBackend(t)
+
+	d := registerFakeDistro(t, "FakeDistro")
+
+	// Emulating /etc/wsl.conf with a buffer
+	var file []byte
+	fileExists := false
+	fake.Script("if [ -e /etc/wsl.conf ]; then cat /etc/wsl.conf; fi", func(p *wsl.FakeProcess) uint32 {
+		if !fileExists {
+			return 0
+		}
+		if _, err := p.Stdout.Write(file); err != nil {
+			return 1
+		}
+		return 0
+	})
+	fake.Script("cat > /etc/wsl.conf.tmp && mv -f /etc/wsl.conf.tmp /etc/wsl.conf", func(p *wsl.FakeProcess) uint32 {
+		if p.User != "root" {
+			return 1
+		}
+		out, err := io.ReadAll(p.Stdin)
+		if err != nil {
+			return 1
+		}
+		file, fileExists = out, true
+		return 0
+	})
+
+	ctx := context.Background()
+
+	conf, err := d.WSLConf(ctx)
+	require.NoError(t, err, "Unexpected error reading a missing wsl.conf")
+	out, err := conf.Bytes()
+	require.NoError(t, err, "Unexpected error serializing an empty configuration")
+	require.Empty(t, out, "A missing wsl.conf should result in an empty configuration")
+
+	file, fileExists = []byte("# Managed by hand\n[user]\ndefault = root\n"), true
+
+	conf, err = d.WSLConf(ctx)
+	require.NoError(t, err, "Unexpected error reading wsl.conf")
+	require.Equal(t, "root", conf.DefaultUser, "Unexpected default user")
+
+	conf.Systemd = ptr(true)
+	require.NoError(t, d.SetWSLConf(ctx, conf), "Unexpected error writing wsl.conf")
+	require.Equal(t, "# Managed by hand\n[user]\ndefault = root\n\n[boot]\nsystemd = true\n", string(file), "Unexpected contents of wsl.conf")
+
+	written := file
+	conf.Hostname = `"devbox"`
+	require.Error(t, d.SetWSLConf(ctx, conf), "Unexpected success writing a value that cannot be serialized")
+	require.Equal(t, written, file, "wsl.conf should not be written if it cannot be serialized")
+	conf.Hostname = ""
+
+	file = []byte("[boot]\nsystemd = perhaps\n")
+	_, err = d.WSLConf(ctx)
+	require.Error(t, err, "Unexpected success reading an invalid wsl.conf")
+
+	fake.Script("cat > /etc/wsl.conf.tmp && mv -f /etc/wsl.conf.tmp /etc/wsl.conf", wsl.FakeResult("", "Permission denied\n", 1))
+	err = d.SetWSLConf(ctx, conf)
+	require.ErrorIs(t, err, wsl.ExitError{}, "Expected an ExitError writing wsl.conf without permissions")
+	require.ErrorContains(t, err, "Permission denied", "Error should contain the output of the command")
+
+	_, err = (&wsl.Distro{Name: "NotRegistered"}).WSLConf(ctx)
+	require.Error(t, err, "Unexpected success reading wsl.conf from an unregistered distro")
+}
@@ -0,0 +1,236 @@
+package wsl
+
+// This file contains the runner used to access functionality only available via wsl.exe.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// wslExePath is the executable used to run wsl.exe commands.
+// It is a variable so that it can be replaced with a stand-in during tests.
+var wslExePath = "wsl.exe"
+
+// wslExe runs wsl.exe with the specified arguments and waits for it to finish. Its standard
+// output is written into stdout, or collected alongside its standard error if stdout is nil.
+// The collected output is included in the error, if any.
+//
+// The process is killed if the context is cancelled before it finishes.
+func wslExe(ctx context.Context, stdout io.Writer, args ...string) error {
+	var out bytes.Buffer
+
+	cmd := wslExeCommand(ctx, args...)
+	cmd.Stdout = stdout
+	if stdout == nil {
+		cmd.Stdout = &out
+	}
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return newWSLExeError(err, out.Bytes())
+	}
+
+	return nil
+}
+
+// wslExeOutput runs wsl.exe with the specified arguments and returns its standard output.
+// If progress is not nil, the standard output is also written into it as it is produced.
+// On failure, both its standard output and error are included in the error.
+//
+// The process is killed if the context is cancelled before it finishes.
+func wslExeOutput(ctx context.Context, progress io.Writer, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := wslExeCommand(ctx, args...)
+	cmd.Stdout = &stdout
+	if progress != nil {
+		cmd.Stdout = io.MultiWriter(&stdout, progress)
+	}
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, newWSLExeError(err, append(stdout.Bytes(), stderr.Bytes()...))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// wslExeCommand prepares a wsl.exe command with the specified arguments.
+func wslExeCommand(ctx context.Context, args ...string) *exec.Cmd {
+	//nolint:gosec // G204: Subprocess launched with variable. The arguments are passed
+	// as-is to wsl.exe without going through a shell, so there is no injection risk.
+	cmd := exec.CommandContext(ctx, wslExePath, args...)
+	cmd.Env = append(os.Environ(), "WSL_UTF8=1") // Otherwise, wsl.exe writes its messages in UTF-16
+	return cmd
+}
+
+// WSLExeError is returned when wsl.exe fails.
+type WSLExeError struct {
+	Code    string // Error code reported by wsl.exe, e.g. Wsl/Service/WSL_E_DISTRO_NOT_FOUND. Empty if none was reported.
+	Message string // Error message written by wsl.exe, without the error code.
+	Err     error  // Error returned when running wsl.exe.
+}
+
+func (e *WSLExeError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%v: %s", e.Err, e.Message)
+	}
+	return fmt.Sprintf("%v: %s (error code: %s)", e.Err, e.Message, e.Code)
+}
+
+func (e *WSLExeError) Unwrap() error {
+	return e.Err
+}
+
+// newWSLExeError parses the output of a failed wsl.exe call. It usually looks like:
+//
+//	There is no distribution with the supplied name.
+//	Error code: Wsl/Service/WSL_E_DISTRO_NOT_FOUND
+func newWSLExeError(err error, output []byte) *WSLExeError {
+	e := &WSLExeError{Err: err}
+
+	var msg []string
+	for _, line := range strings.Split(decodeWSLExeOutput(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "Error code:") {
+			e.Code = strings.TrimSpace(strings.TrimPrefix(line, "Error code:"))
+			continue
+		}
+		msg = append(msg, line)
+	}
+	e.Message = strings.Join(msg, " ")
+
+	return e
+}
+
+// decodeWSLExeOutput converts the output of wsl.exe into a string. Even though wsl.exe is asked
+// to use UTF-8, versions that predate WSL_UTF8 write their messages in UTF-16LE.
+func decodeWSLExeOutput(output []byte) string {
+	output = bytes.TrimPrefix(output, []byte{0xff, 0xfe}) // UTF-16LE byte order mark
+
+	// ASCII text encoded as UTF-16LE has a null byte after every character
+	if len(output) < 2 || len(output)%2 != 0 || output[1] != 0 {
+		return string(output)
+	}
+
+	u := make([]uint16, 0, len(output)/2)
+	for i := 0; i+1 < len(output); i += 2 {
+		u = append(u, uint16(output[i])|uint16(output[i+1])<<8)
+	}
+	return string(utf16.Decode(u))
+}
+
+// exportDistro exports the filesystem of a distro into dst.
+//
+// It is analogous to
+//
+//	`wsl.exe --export <distroName> - [--vhd]`
+func exportDistro(ctx context.Context, distroName string, dst io.Writer, format Format) error {
+	args := []string{"--export", distroName, "-"}
+	switch format {
+	case FormatTar:
+	case FormatVHD:
+		args = append(args, "--vhd")
+	default:
+		return fmt.Errorf("unknown format %d", format)
+	}
+
+	if err := wslExe(ctx, dst, args...); err != nil {
+		return fmt.Errorf("error exporting distro %q: %w", distroName, err)
+	}
+	return nil
+}
+
+// importDistro creates a new distro from a tarball or a VHD, stored in installDir.
+// A version of zero means that the default WSL version is used.
+//
+// It is analogous to
+//
+//	`wsl.exe --import <distroName> <installDir> <source> [--version <version>] [--vhd]`
+func importDistro(ctx context.Context, distroName string, installDir string, source string, format Format, version uint8) error {
+	args := []string{"--import", distroName, installDir, source}
+
+	switch version {
+	case 0:
+	case 1, 2:
+		args = append(args, "--version", strconv.Itoa(int(version)))
+	default:
+		return fmt.Errorf("error importing distro %q: unknown WSL version %d", distroName, version)
+	}
+
+	switch format {
+	case FormatTar:
+	case FormatVHD:
+		args = append(args, "--vhd")
+	default:
+		return fmt.Errorf("error importing distro %q: unknown format %d", distroName, format)
+	}
+
+	if err := wslExe(ctx, nil, args...); err != nil {
+		return fmt.Errorf("error importing distro %q: %w", distroName, err)
+	}
+	return nil
+}
+
+// importDistroInPlace creates a new distro that uses an existing VHD as its filesystem.
+//
+// It is analogous to
+//
+//	`wsl.exe --import-in-place <distroName> <vhdx>`
+func importDistroInPlace(ctx context.Context, distroName string, vhdx string) error {
+	if err := wslExe(ctx, nil, "--import-in-place", distroName, vhdx); err != nil {
+		return fmt.Errorf("error importing distro %q: %w", distroName, err)
+	}
+	return nil
+}
+
+// listVerbose returns the state of every registered distro, indexed by name.
+//
+// It is analogous to
+//
+//	`wsl.exe --list --verbose`
+func listVerbose(ctx context.Context) (map[string]State, error) {
+	out, err := wslExeOutput(ctx, nil, "--list", "--verbose")
+	if err != nil {
+		return nil, fmt.Errorf("error listing distros: %w", err)
+	}
+
+	states, err := parseListVerbose(out)
+	if err != nil {
+		return nil, fmt.Errorf("error listing distros: %w", err)
+	}
+	return states, nil
+}
+
+// setVersion converts a distro to the specified WSL version. The progress reported by
+// wsl.exe is written into progress, unless it is nil.
+//
+// It is analogous to
+//
+//	`wsl.exe --set-version <distroName> <version>`
+func setVersion(ctx context.Context, distroName string, version uint8, progress io.Writer) error {
+	if version != 1 && version != 2 {
+		return fmt.Errorf("error setting version of %q: unknown WSL version %d", distroName, version)
+	}
+
+	if _, err := wslExeOutput(ctx, progress, "--set-version", distroName, strconv.Itoa(int(version))); err != nil {
+		return fmt.Errorf("error setting version of %q: %w", distroName, err)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package wsl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The test binary doubles as a stand-in for wsl.exe: when standInRecordEnv is set,
+// it records its arguments and behaves as instructed by the other environment
+// variables instead of running the tests. Its output is read from the files that
+// standInStdoutEnv and standInStderrEnv point to, in that order, followed by its input if
+// standInEchoEnv is set.
+const (
+	standInRecordEnv = "GOWSL_STANDIN_RECORD"
+	standInStdoutEnv = "GOWSL_STANDIN_STDOUT"
+	standInStderrEnv = "GOWSL_STANDIN_STDERR"
+	standInExitEnv   = "GOWSL_STANDIN_EXIT"
+	standInSleepEnv  = "GOWSL_STANDIN_SLEEP"
+	standInEchoEnv   = "GOWSL_STANDIN_ECHO"
+)
+
+func init() {
+	record := os.Getenv(standInRecordEnv)
+	if record == "" {
+		return
+	}
+
+	out, err := json.Marshal(os.Args[1:])
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(record, out, 0600); err != nil {
+		panic(err)
+	}
+
+	if d, err := time.ParseDuration(os.Getenv(standInSleepEnv)); err == nil {
+		time.Sleep(d)
+	}
+
+	// Stdout is written before stderr, so that the order of combined output is predictable
+	for _, stream := range []struct {
+		env string
+		w   *os.File
+	}{{standInStdoutEnv, os.Stdout}, {standInStderrEnv, os.Stderr}} {
+		out, err := os.ReadFile(os.Getenv(stream.env))
+		if err != nil {
+			panic(err)
+		}
+		if _, err := stream.w.Write(out); err != nil {
+			panic(err)
+		}
+	}
+
+	if os.Getenv(standInEchoEnv) != "" {
+		if _, err := io.Copy(os.Stdout, os.Stdin); err != nil {
+			panic(err)
+		}
+	}
+
+	code, _ := strconv.Atoi(os.Getenv(standInExitEnv))
+	os.Exit(code)
+}
+
+// standIn describes how the wsl.exe stand-in behaves.
+type standIn struct {
+	stdout   string
+	stderr   string
+	exitCode int
+	sleep    time.Duration
+	echo     bool // Whether the standard input is copied into the standard output
+}
+
+// useStandInWslExe replaces wsl.exe with the test binary, behaving as described by s, for
+// the duration of the test. The returned function returns the arguments it was called with.
+func useStandInWslExe(t *testing.T, s standIn) (recordedArgs func() []string) {
+	t.Helper()
+
+	exe, err := os.Executable()
+	require.NoError(t, err, "Setup: could not find the test executable")
+
+	// The output is passed via files, as environment variables cannot contain null characters
+	dir := t.TempDir()
+	for env, out := range map[string]string{standInStdoutEnv: s.stdout, standInStderrEnv: s.stderr} {
+		path := filepath.Join(dir, env)
+		require.NoError(t, os.WriteFile(path, []byte(out), 0600), "Setup: could not write the output of the wsl.exe stand-in")
+		t.Setenv(env, path)
+	}
+
+	record := filepath.Join(dir, "args.json")
+	t.Setenv(standInRecordEnv, record)
+	t.Setenv(standInExitEnv, strconv.Itoa(s.exitCode))
+	t.Setenv(standInSleepEnv, s.sleep.String())
+	if s.echo {
+		t.Setenv(standInEchoEnv, "1")
+	}
+
+	prev := wslExePath
+	wslExePath = exe
+	t.Cleanup(func() { wslExePath = prev })
+
+	return func() []string {
+		t.Helper()
+
+		out, err := os.ReadFile(record)
+		require.NoError(t, err, "wsl.exe stand-in was not called")
+
+		var args []string
+		require.NoError(t, json.Unmarshal(out, &args), "Could not parse the arguments recorded by the wsl.exe stand-in")
+		return args
+	}
+}
+
+func TestExportDistro(t *testing.T) {
+	testCases := map[string]struct {
+		format   Format
+		standIn  standIn
+		cancel   bool
+		wantArgs []string
+		wantErr  bool
+	}{
+		"tar": {format: FormatTar, standIn: standIn{stdout: "tarball"}, wantArgs: []string{"--export", "SomeDistro", "-"}},
+		"vhd": {format: FormatVHD, standIn: standIn{stdout: "vhdxfile"}, wantArgs: []string{"--export", "SomeDistro", "-", "--vhd"}},
+
+		// Error cases
+		"error when wsl.exe fails":       {standIn: standIn{stderr: "There is no distribution with the supplied name.", exitCode: 1}, wantErr: true},
+		"error with an unknown format":   {format: Format(42), wantErr: true},
+		"error with a cancelled context": {standIn: standIn{sleep: time.Minute}, cancel: true, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			recordedArgs := useStandInWslExe(t, tc.standIn)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				time.AfterFunc(100*time.Millisecond, cancel)
+			}
+
+			var out bytes.Buffer
+			err := exportDistro(ctx, "SomeDistro", &out, tc.format)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success exporting a distro")
+				if tc.standIn.stderr != "" {
+					require.ErrorContains(t, err, tc.standIn.stderr, "Error should contain the output of wsl.exe")
+				}
+				if tc.cancel {
+					require.ErrorIs(t, err, context.Canceled, "Error should be caused by the cancelled context")
+				}
+				return
+			}
+			require.NoError(t, err, "Unexpected error exporting a distro")
+			require.Equal(t, tc.wantArgs, recordedArgs(), "Unexpected arguments passed to wsl.exe")
+			require.Equal(t, tc.standIn.stdout, out.String(), "Output of wsl.exe should be written into the destination")
+		})
+	}
+}
+
+func TestImportDistro(t *testing.T) {
+	testCases := map[string]struct {
+		format   Format
+		version  uint8
+		inPlace  bool
+		standIn  standIn
+		wantArgs []string
+		wantCode string
+		wantErr  bool
+	}{
+		"tar with default version": {wantArgs: []string{"--import", "SomeDistro", `C:\distros\SomeDistro`, `C:\rootfs.tar`}},
+		"tar with version 1":       {version: 1, wantArgs: []string{"--import", "SomeDistro", `C:\distros\SomeDistro`, `C:\rootfs.tar`, "--version", "1"}},
+		"vhd with version 2":       {format: FormatVHD, version: 2, wantArgs: []string{"--import", "SomeDistro", `C:\distros\SomeDistro`, `C:\rootfs.tar`, "--version", "2", "--vhd"}},
+		"in place":                 {inPlace: true, wantArgs: []string{"--import-in-place", "SomeDistro", `C:\rootfs.tar`}},
+
+		// Error cases
+		"error with an unknown version": {version: 3, wantErr: true},
+		"error with an unknown format":  {format: Format(42), wantErr: true},
+		"error when wsl.exe fails": {
+			standIn: standIn{
+				stdout:   "A distribution with the supplied name already exists.\r\nError code: Wsl/Service/RegisterDistro/ERROR_ALREADY_EXISTS\r\n",
+				exitCode: 1,
+			},
+			wantCode: "Wsl/Service/RegisterDistro/ERROR_ALREADY_EXISTS",
+			wantErr:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			recordedArgs := useStandInWslExe(t, tc.standIn)
+
+			var err error
+			if tc.inPlace {
+				err = importDistroInPlace(context.Background(), "SomeDistro", `C:\rootfs.tar`)
+			} else {
+				err = importDistro(context.Background(), "SomeDistro", `C:\distros\SomeDistro`, `C:\rootfs.tar`, tc.format, tc.version)
+			}
+
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success importing a distro")
+				if tc.wantCode != "" {
+					var target *WSLExeError
+					require.ErrorAs(t, err, &target, "Expected a WSLExeError")
+					require.Equal(t, tc.wantCode, target.Code, "Unexpected error code")
+				}
+				return
+			}
+			require.NoError(t, err, "Unexpected error importing a distro")
+			require.Equal(t, tc.wantArgs, recordedArgs(), "Unexpected arguments passed to wsl.exe")
+		})
+	}
+}
+
+func TestNewWSLExeError(t *testing.T) {
+	t.Parallel()
+
+	utf16le := func(s string) []byte {
+		b := []byte{0xff, 0xfe}
+		for _, r := range s {
+			b = append(b, byte(r), 0)
+		}
+		return b
+	}
+
+	testCases := map[string]struct {
+		output []byte
+
+		wantCode    string
+		wantMessage string
+	}{
+		"message and error code": {
+			output:      []byte("There is no distribution with the supplied name.\r\nError code: Wsl/Service/WSL_E_DISTRO_NOT_FOUND\r\n"),
+			wantCode:    "Wsl/Service/WSL_E_DISTRO_NOT_FOUND",
+			wantMessage: "There is no distribution with the supplied name.",
+		},
+		"multi-line message": {
+			output:      []byte("The operation could not be completed.\r\nThe file is in use.\r\nError code: Wsl/Service/ERROR_SHARING_VIOLATION\r\n"),
+			wantCode:    "Wsl/Service/ERROR_SHARING_VIOLATION",
+			wantMessage: "The operation could not be completed. The file is in use.",
+		},
+		"message without error code": {
+			output:      []byte("Invalid command line argument: --foo\n"),
+			wantMessage: "Invalid command line argument: --foo",
+		},
+		"UTF-16 output": {
+			output:      utf16le("There is no distribution with the supplied name.\r\nError code: Wsl/Service/WSL_E_DISTRO_NOT_FOUND\r\n"),
+			wantCode:    "Wsl/Service/WSL_E_DISTRO_NOT_FOUND",
+			wantMessage: "There is no distribution with the supplied name.",
+		},
+		"no output": {output: []byte{}},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			exitErr := errors.New("exit status 1")
+			err := newWSLExeError(exitErr, tc.output)
+
+			require.Equal(t, tc.wantCode, err.Code, "Unexpected error code")
+			require.Equal(t, tc.wantMessage, err.Message, "Unexpected error message")
+			require.ErrorIs(t, err, exitErr, "WSLExeError should wrap the error from running wsl.exe")
+		})
+	}
+}
+
+func TestSetVersion(t *testing.T) {
+	testCases := map[string]struct {
+		version  uint8
+		standIn  standIn
+		wantArgs []string
+		wantCode string
+		wantErr  bool
+	}{
+		"version 1": {version: 1, standIn: standIn{stdout: "Conversion in progress, this may take a few minutes...\n"}, wantArgs: []string{"--set-version", "SomeDistro", "1"}},
+		"version 2": {version: 2, standIn: standIn{stdout: "Conversion in progress, this may take a few minutes...\n"}, wantArgs: []string{"--set-version", "SomeDistro", "2"}},
+
+		// Error cases
+		"error with an unknown version": {version: 3, wantErr: true},
+		"error when wsl.exe fails": {
+			version: 2,
+			standIn: standIn{
+				stdout:   "Conversion in progress, this may take a few minutes...\nThere is no distribution with the supplied name.\nError code: Wsl/Service/WSL_E_DISTRO_NOT_FOUND\n",
+				exitCode: 1,
+			},
+			wantCode: "Wsl/Service/WSL_E_DISTRO_NOT_FOUND",
+			wantErr:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			recordedArgs := useStandInWslExe(t, tc.standIn)
+
+			var progress bytes.Buffer
+			err := setVersion(context.Background(), "SomeDistro", tc.version, &progress)
+			if tc.wantErr {
+				require.Error(t, err, "Unexpected success setting the WSL version")
+				if tc.wantCode != "" {
+					var target *WSLExeError
+					require.ErrorAs(t, err, &target, "Expected a WSLExeError")
+					require.Equal(t, tc.wantCode, target.Code, "Unexpected error code")
+				}
+				return
+			}
+			require.NoError(t, err, "Unexpected error setting the WSL version")
+			require.Equal(t, tc.wantArgs, recordedArgs(), "Unexpected arguments passed to wsl.exe")
+			require.Equal(t, tc.standIn.stdout, progress.String(), "Output of wsl.exe should be streamed into the progress writer")
+		})
+	}
+}